@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pulsegrade/test1/health"
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	mux := NewMux(metrics.NewRegistry(metrics.Options{}), health.NewChecker(time.Second), http.NotFoundHandler(), false)
+	handler := AuthMiddleware(mux, models.AdminAuthConfig{Token: "secret-token", Username: "admin", Password: "secret-password"})
+
+	tests := []struct {
+		name               string
+		path               string
+		setup              func(r *http.Request)
+		expectedStatusCode int
+	}{
+		{name: "No credentials", path: "/metrics", setup: func(r *http.Request) {}, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Wrong token", path: "/metrics", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Valid token", path: "/metrics", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret-token") }, expectedStatusCode: http.StatusOK},
+		{name: "Wrong password", path: "/metrics", setup: func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Valid basic auth", path: "/metrics", setup: func(r *http.Request) { r.SetBasicAuth("admin", "secret-password") }, expectedStatusCode: http.StatusOK},
+		{name: "Liveness probe needs no credentials", path: "/healthz", setup: func(r *http.Request) {}, expectedStatusCode: http.StatusOK},
+		{name: "Readiness probe needs no credentials", path: "/readyz", setup: func(r *http.Request) {}, expectedStatusCode: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			tc.setup(req)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatusCode {
+				t.Errorf("expected status code %d but got %d", tc.expectedStatusCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestPprofToggle(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		mux := NewMux(metrics.NewNoop(), health.NewChecker(time.Second), http.NotFoundHandler(), enabled)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+
+		if (w.Code == http.StatusOK) != enabled {
+			t.Errorf("expected pprof enabled=%v but got status %d", enabled, w.Code)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package api assembles the public HTTP API: the versioned /v1 routes, the
+// deprecated unversioned aliases and the OpenAPI document describing them
+package api
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"pulsegrade/test1/handlers"
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+)
+
+// Version is the current API version, the prefix of every versioned route
+const Version = "v1"
+
+// openAPISpec is the OpenAPI 3 document served at /v1/openapi.json
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// route is a versioned API route
+type route struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+}
+
+// routes returns the /v1 route table; the OpenAPI document must describe exactly these routes
+func routes(income *handlers.IncomeSalaryHandler) []route {
+	return []route{
+		{method: http.MethodGet, path: "/v1/income-salary", handler: income.Handle},
+		{method: http.MethodPost, path: "/v1/income-salary", handler: income.Handle},
+		{method: http.MethodPost, path: "/v1/income-salary/batch", handler: income.HandleBatch},
+		{method: http.MethodGet, path: "/v1/tax-brackets/{year}", handler: income.HandleBrackets},
+		{method: http.MethodGet, path: "/v1/tax-comparison", handler: income.HandleComparison},
+		{method: http.MethodGet, path: "/v1/tax-curve/{year}", handler: income.HandleCurve},
+		{method: http.MethodGet, path: "/v1/openapi.json", handler: serveOpenAPI},
+	}
+}
+
+// legacyRoutes maps the deprecated unversioned routes to their /v1 successors
+var legacyRoutes = map[string]string{
+	"/income-salary":       "/v1/income-salary",
+	"/income-salary/batch": "/v1/income-salary/batch",
+}
+
+// NewMux creates the public API ServeMux
+func NewMux(income *handlers.IncomeSalaryHandler, config models.APIConfig) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Patterns are registered without a method so metrics, access logs and spans
+	// are labeled by path alone; methods are dispatched by byMethod
+	byPath := map[string]byMethod{}
+	var paths []string
+	for _, r := range routes(income) {
+		if byPath[r.path] == nil {
+			byPath[r.path] = byMethod{}
+			paths = append(paths, r.path)
+		}
+		byPath[r.path][r.method] = r.handler
+	}
+	for _, path := range paths {
+		mux.Handle(path, byPath[path])
+	}
+
+	deprecation := newDeprecation(config)
+	mux.Handle("/income-salary", deprecation.wrap(http.HandlerFunc(income.Handle), legacyRoutes["/income-salary"]))
+	mux.Handle("/income-salary/batch", deprecation.wrap(http.HandlerFunc(income.HandleBatch), legacyRoutes["/income-salary/batch"]))
+
+	return mux
+}
+
+// byMethod dispatches a route to the handler registered for the request method
+type byMethod map[string]http.HandlerFunc
+
+// ServeHTTP calls the handler of the request method, answering 405 with an Allow header otherwise
+func (m byMethod) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := m[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+	if handler, ok := m[http.MethodGet]; ok && r.Method == http.MethodHead {
+		handler(w, r)
+		return
+	}
+
+	allowed := make([]string, 0, len(m))
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	handlers.WriteProblem(w, r, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed,
+		fmt.Sprintf("%s is not supported; use %s", r.Method, strings.Join(allowed, " or ")))
+}
+
+// serveOpenAPI serves the embedded OpenAPI document
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// deprecation holds the headers announcing the retirement of the unversioned routes
+type deprecation struct {
+	deprecation string // RFC 9745 Deprecation header value
+	sunset      string // RFC 8594 Sunset header value
+}
+
+// newDeprecation parses the configured dates (YYYY-MM-DD); a header whose date
+// is missing or invalid is omitted
+func newDeprecation(config models.APIConfig) deprecation {
+	var d deprecation
+	if date, err := time.Parse(time.DateOnly, config.LegacyDeprecation); err == nil {
+		d.deprecation = fmt.Sprintf("@%d", date.Unix())
+	} else if config.LegacyDeprecation != "" {
+		logger.Warn("Ignoring invalid api.legacyDeprecation date %q: %v", config.LegacyDeprecation, err)
+	}
+	if date, err := time.Parse(time.DateOnly, config.LegacySunset); err == nil {
+		d.sunset = date.UTC().Format(http.TimeFormat)
+	} else if config.LegacySunset != "" {
+		logger.Warn("Ignoring invalid api.legacySunset date %q: %v", config.LegacySunset, err)
+	}
+	return d
+}
+
+// wrap adds Deprecation, Sunset and successor Link headers to the responses of a deprecated route
+func (d deprecation) wrap(next http.Handler, successor string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.deprecation != "" {
+			w.Header().Set("Deprecation", d.deprecation)
+		}
+		if d.sunset != "" {
+			w.Header().Set("Sunset", d.sunset)
+		}
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
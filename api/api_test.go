@@ -0,0 +1,243 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"pulsegrade/test1/batch"
+	"pulsegrade/test1/handlers"
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+)
+
+// openAPIDocument is the part of an OpenAPI document checked against the code
+type openAPIDocument struct {
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Responses map[string]openAPIResponse `json:"responses"`
+		Schemas   map[string]openAPISchema   `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema openAPISchema `json:"schema"`
+	} `json:"content"`
+}
+
+type openAPISchema struct {
+	Ref        string                   `json:"$ref"`
+	Properties map[string]openAPISchema `json:"properties"`
+}
+
+func loadSpec(t *testing.T) openAPIDocument {
+	t.Helper()
+	var spec openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return spec
+}
+
+// resolveSchema follows a components/schemas reference
+func (d openAPIDocument) resolveSchema(schema openAPISchema) openAPISchema {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		return d.Components.Schemas[name]
+	}
+	return schema
+}
+
+// successSchema returns the JSON schema of an operation's 200 response
+func (d openAPIDocument) successSchema(op openAPIOperation) openAPISchema {
+	response := op.Responses["200"]
+	if name, ok := strings.CutPrefix(response.Ref, "#/components/responses/"); ok {
+		response = d.Components.Responses[name]
+	}
+	return d.resolveSchema(response.Content["application/json"].Schema)
+}
+
+// jsonFields returns the JSON property names of a struct type
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	spec := loadSpec(t)
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	for _, r := range routes(&handlers.IncomeSalaryHandler{}) {
+		registered[r.method+" "+r.path] = true
+	}
+
+	for route := range registered {
+		if !documented[route] {
+			t.Errorf("route %s is not documented in openapi.json", route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("openapi.json documents %s, which is not a registered route", route)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	spec := loadSpec(t)
+
+	models := map[string]interface{}{
+		"Request":            models.Request{},
+		"Response":           models.Response{},
+		"TaxBracket":         models.TaxBracket{},
+		"BracketsResponse":   models.BracketsResponse{},
+		"ScheduleBracket":    models.ScheduleBracket{},
+		"CurrentBracket":     models.CurrentBracket{},
+		"JurisdictionTax":    models.JurisdictionTax{},
+		"ComparisonResponse": models.ComparisonResponse{},
+		"YearTax":            models.YearTax{},
+		"YearChange":         models.YearChange{},
+		"CurveResponse":      models.CurveResponse{},
+		"CurvePoint":         models.CurvePoint{},
+		"BracketTax":         models.BracketTax{},
+		"BatchResult":        batch.Result{},
+		"BatchSummary":       batch.Summary{},
+		"Problem":            models.Problem{},
+		"FieldError":         models.FieldError{},
+	}
+
+	for name, model := range models {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := spec.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("openapi.json has no %s schema", name)
+			}
+
+			var properties []string
+			for property := range schema.Properties {
+				properties = append(properties, property)
+			}
+			sort.Strings(properties)
+
+			if fields := jsonFields(reflect.TypeOf(model)); !reflect.DeepEqual(fields, properties) {
+				t.Errorf("schema %s has properties %v but the Go type has fields %v", name, properties, fields)
+			}
+		})
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	spec := loadSpec(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":50000,"rate":0.2}]}`)
+	}))
+	defer upstream.Close()
+
+	config := models.Config{
+		TaxCalcBaseURL: upstream.URL,
+		Batch:          models.BatchConfig{MaxItems: 10, MaxBodyBytes: 1024},
+	}
+	mux := NewMux(handlers.NewIncomeSalaryHandler(config, metrics.NewNoop()), config.API)
+
+	tests := []struct {
+		method string
+		path   string
+		route  string
+		body   string
+	}{
+		{method: http.MethodGet, path: "/v1/income-salary?salary=60000", route: "/v1/income-salary"},
+		{method: http.MethodPost, path: "/v1/income-salary", route: "/v1/income-salary", body: `{"salary":60000}`},
+		{method: http.MethodPost, path: "/v1/income-salary/batch", route: "/v1/income-salary/batch", body: `[{"id":"e1","salary":60000}]`},
+		{method: http.MethodGet, path: "/v1/tax-brackets/2022", route: "/v1/tax-brackets/{year}"},
+		{method: http.MethodGet, path: "/v1/tax-comparison?salary=60000&years=2021,2022", route: "/v1/tax-comparison"},
+		{method: http.MethodGet, path: "/v1/tax-curve/2022?to=100000&step=25000", route: "/v1/tax-curve/{year}"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.route, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+			}
+
+			schema := spec.successSchema(spec.Paths[tc.route][strings.ToLower(tc.method)])
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(body) == 0 {
+				t.Fatalf("expected a non-empty response object")
+			}
+			for field := range body {
+				if _, ok := schema.Properties[field]; !ok {
+					t.Errorf("response field %q is not documented", field)
+				}
+			}
+		})
+	}
+}
+
+func TestRouting(t *testing.T) {
+	config := models.Config{TaxCalcBaseURL: "http://127.0.0.1:1"}
+	mux := NewMux(handlers.NewIncomeSalaryHandler(config, metrics.NewNoop()),
+		models.APIConfig{LegacyDeprecation: "2026-11-01", LegacySunset: "2027-05-01"})
+
+	req := httptest.NewRequest(http.MethodGet, "/income-salary", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Deprecation"); got != "@1793491200" {
+		t.Errorf("expected Deprecation @1793491200 but got %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Sat, 01 May 2027 00:00:00 GMT" {
+		t.Errorf("expected Sunset of 1 May 2027 but got %q", got)
+	}
+	if got := w.Header().Get("Link"); got != `</v1/income-salary>; rel="successor-version"` {
+		t.Errorf("expected a successor-version link but got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" {
+		t.Errorf("expected the OpenAPI document without deprecation headers but got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/income-salary", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("expected 405 allowing GET, POST but got %d allowing %q", w.Code, w.Header().Get("Allow"))
+	}
+}
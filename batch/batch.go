@@ -0,0 +1,245 @@
+// Package batch calculates tax for many salaries at once, e.g. a payroll run read from a CSV file
+package batch
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+	"pulsegrade/test1/validation"
+)
+
+// Record is one input row
+type Record struct {
+	Line   int     // 1-based line number (or JSON array item) in the input, for error messages
+	ID     string  // Caller-supplied identifier (e.g. employee number), echoed in the result
+	Salary float64 // Annual salary
+	Year   int     // Tax year (0 for the default year)
+	Err    error   // Set when the row could not be parsed; the row is reported as failed
+}
+
+// Result is the outcome of one record
+type Result struct {
+	Line          int                 `json:"line"`
+	ID            string              `json:"id"`
+	Salary        float64             `json:"salary"`
+	Year          int                 `json:"year,omitempty"`
+	Tax           float64             `json:"tax"`
+	EffectiveRate float64             `json:"effective_rate"`
+	Breakdown     []models.BracketTax `json:"breakdown,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	Code          string              `json:"code,omitempty"` // Machine-readable error code (see models.Code*)
+}
+
+// Summary holds the totals of a batch
+type Summary struct {
+	Rows          int     `json:"rows"`
+	Succeeded     int     `json:"succeeded"`
+	Failed        int     `json:"failed"`
+	TotalSalary   float64 `json:"total_salary"`
+	TotalTax      float64 `json:"total_tax"`
+	EffectiveRate float64 `json:"effective_rate"` // Total tax over total salary of the successful rows
+	Years         []int   `json:"years"`          // Tax years whose brackets were fetched (0 is the default year)
+	DurationMs    float64 `json:"duration_ms"`
+}
+
+// Options controls how a batch is processed
+type Options struct {
+	BaseURL        string            // Tax calculator base URL
+	IncludeTaxYear bool              // Request each row's year, or the current year for rows without one; otherwise the upstream default year (like the API does)
+	Workers        int               // Parallel calculations (0 uses the number of CPUs)
+	Breakdown      bool              // Include the per-bracket breakdown in each result
+	Rules          *validation.Rules // Bounds each row's salary and year are checked against (nil for none)
+}
+
+// Processor calculates tax for batches of records
+type Processor struct {
+	calculator *services.TaxCalculator
+	options    Options
+}
+
+// NewProcessor creates a processor fetching brackets and calculating tax with the given calculator
+func NewProcessor(calculator *services.TaxCalculator, options Options) *Processor {
+	if options.Workers <= 0 {
+		options.Workers = runtime.NumCPU()
+	}
+	return &Processor{calculator: calculator, options: options}
+}
+
+// Process calculates the tax of every record. The brackets of each tax year are
+// fetched once, then the rows are calculated in parallel. Results are returned in
+// input order; rows that could not be parsed or whose brackets could not be
+// fetched carry an error instead of a tax amount.
+func (p *Processor) Process(ctx context.Context, records []Record) ([]Result, Summary) {
+	start := time.Now()
+
+	records, taxYears, brackets, years := p.prepare(ctx, records)
+
+	results := make([]Result, len(records))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < p.options.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = p.calculate(records[i], taxYears[i], brackets[taxYears[i]])
+			}
+		}()
+	}
+	for i := range records {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	summary := Summarize(results)
+	summary.Years = years
+	summary.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return results, summary
+}
+
+// Stream calculates the tax of every record like Process, but hands each result
+// to emit as soon as it is calculated, in input order, so large batches can be
+// written out without holding every result in memory. It stops at the first
+// error returned by emit.
+func (p *Processor) Stream(ctx context.Context, records []Record, emit func(Result) error) (Summary, error) {
+	start := time.Now()
+
+	records, taxYears, brackets, years := p.prepare(ctx, records)
+
+	summary := Summary{Years: years}
+	for i, record := range records {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		result := p.calculate(record, taxYears[i], brackets[taxYears[i]])
+		summary.add(result)
+		if err := emit(result); err != nil {
+			return summary, err
+		}
+	}
+	summary.finish()
+	summary.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return summary, nil
+}
+
+// prepare validates the records and resolves the tax year of each, then fetches
+// each year's brackets once. Rows failing validation are returned with Err set.
+func (p *Processor) prepare(ctx context.Context, records []Record) ([]Record, []int, map[int]services.YearBrackets, []int) {
+	records = p.validate(records)
+	taxYears := make([]int, len(records))
+	for i, record := range records {
+		taxYears[i] = p.taxYear(record.Year)
+	}
+	brackets, years := p.fetchBrackets(ctx, records, taxYears)
+	return records, taxYears, brackets, years
+}
+
+// validate checks the parsed records against the rules, returning a copy in
+// which the rows out of bounds are marked as failed
+func (p *Processor) validate(records []Record) []Record {
+	if p.options.Rules == nil {
+		return records
+	}
+
+	validated := make([]Record, len(records))
+	for i, record := range records {
+		validated[i] = record
+		if record.Err != nil {
+			continue
+		}
+		if fields := p.options.Rules.Check(record.Salary, record.Year); len(fields) > 0 {
+			validated[i].Err = errors.New(fields[0].Message)
+		}
+	}
+	return validated
+}
+
+// taxYear returns the year whose brackets apply to a row. Like /income-salary,
+// the tax calculator is only asked for a year when includeTaxYear is set.
+func (p *Processor) taxYear(year int) int {
+	return services.ResolveTaxYear(p.options.IncludeTaxYear, year)
+}
+
+// fetchBrackets fetches the brackets of every tax year used by a valid row, in
+// parallel, returning them by year along with the sorted list of years
+func (p *Processor) fetchBrackets(ctx context.Context, records []Record, taxYears []int) (map[int]services.YearBrackets, []int) {
+	brackets := map[int]services.YearBrackets{}
+	var years []int
+	for i, record := range records {
+		if _, ok := brackets[taxYears[i]]; ok || record.Err != nil {
+			continue
+		}
+		brackets[taxYears[i]] = services.YearBrackets{}
+		years = append(years, taxYears[i])
+	}
+	sort.Ints(years)
+
+	for _, result := range p.calculator.FetchYears(ctx, p.options.BaseURL, years) {
+		if result.Err != nil {
+			// Results only carry a generic message and code; the details are logged once per year
+			logger.Error("Failed to fetch tax brackets for year %d: %v", result.Year, result.Err)
+		}
+		brackets[result.Year] = result
+	}
+
+	return brackets, years
+}
+
+// calculate computes the result of one row
+func (p *Processor) calculate(record Record, taxYear int, brackets services.YearBrackets) Result {
+	result := Result{Line: record.Line, ID: record.ID, Salary: record.Salary, Year: taxYear}
+
+	switch {
+	case record.Err != nil:
+		result.Error, result.Code = record.Err.Error(), models.CodeValidation
+		return result
+	case brackets.Err != nil:
+		result.Error, result.Code = "failed to fetch tax brackets", services.ErrorCode(brackets.Err)
+		return result
+	}
+
+	result.Tax, result.EffectiveRate = p.calculator.CalculateTax(record.Salary, brackets.Brackets)
+	result.Tax = math.Round(result.Tax*100) / 100 // Rounded to cents like single calculations
+	if p.options.Breakdown {
+		result.Breakdown = p.calculator.CalculateBreakdown(record.Salary, brackets.Brackets)
+	}
+	return result
+}
+
+// Summarize totals a set of results
+func Summarize(results []Result) Summary {
+	var summary Summary
+	for _, result := range results {
+		summary.add(result)
+	}
+	summary.finish()
+	return summary
+}
+
+// add counts one result
+func (s *Summary) add(result Result) {
+	s.Rows++
+	if result.Error != "" {
+		s.Failed++
+		return
+	}
+	s.Succeeded++
+	s.TotalSalary += result.Salary
+	s.TotalTax += result.Tax
+}
+
+// finish computes the overall effective rate once every result was added
+func (s *Summary) finish() {
+	if s.TotalSalary > 0 {
+		s.EffectiveRate = math.Round((s.TotalTax/s.TotalSalary)*1000) / 1000
+	}
+}
@@ -0,0 +1,190 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+	"pulsegrade/test1/validation"
+)
+
+func TestReadRecords(t *testing.T) {
+	t.Run("CSV", func(t *testing.T) {
+		input := "ID, Salary, Year\ne1,60000,2022\ne2,abc,2022\ne3,40000\n"
+		records, err := ReadRecords(strings.NewReader(input), FormatCSV, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("expected 3 records but got %d", len(records))
+		}
+		if records[0].ID != "e1" || records[0].Salary != 60000 || records[0].Year != 2022 || records[0].Line != 2 {
+			t.Errorf("unexpected first record %+v", records[0])
+		}
+		if records[1].Err == nil {
+			t.Errorf("expected an error for an invalid salary")
+		}
+		if records[2].Err != nil || records[2].Year != 0 {
+			t.Errorf("expected a short row to use the default year but got %+v", records[2])
+		}
+	})
+
+	t.Run("CSV without salary column", func(t *testing.T) {
+		if _, err := ReadRecords(strings.NewReader("id,year\ne1,2022\n"), FormatCSV, 0); err == nil {
+			t.Errorf("expected an error for a missing salary column")
+		}
+	})
+
+	t.Run("JSON Lines", func(t *testing.T) {
+		input := `{"id":"e1","salary":60000,"year":2022}` + "\n\n" + `{"id":42,"salary":1e5}` + "\n" + `{"id":"e3"` + "\n"
+		records, err := ReadRecords(strings.NewReader(input), FormatJSONL, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("expected 3 records but got %d", len(records))
+		}
+		if records[1].ID != "42" || records[1].Salary != 100000 || records[1].Line != 3 {
+			t.Errorf("unexpected second record %+v", records[1])
+		}
+		if records[2].Err == nil {
+			t.Errorf("expected an error for invalid JSON")
+		}
+	})
+}
+
+func TestProcess(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/tax-year/2019" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":50000,"rate":0.2}]}`)
+	}))
+	defer server.Close()
+
+	calculator := services.NewTaxCalculatorWithFullConfig("test", false, models.CircuitBreakerConfig{}, metrics.NewNoop())
+	rules := validation.New(models.ValidationConfig{MinTaxYear: 2000})
+	processor := NewProcessor(calculator, Options{BaseURL: server.URL, IncludeTaxYear: true, Workers: 4, Breakdown: true, Rules: rules})
+
+	var records []Record
+	for i := 0; i < 100; i++ {
+		records = append(records, Record{Line: i + 2, ID: fmt.Sprintf("e%d", i), Salary: 60000, Year: 2021 + i%2})
+	}
+	records = append(records,
+		Record{Line: 102, ID: "default", Salary: 0},
+		Record{Line: 103, ID: "missing-year", Salary: 60000, Year: 2019},
+		Record{Line: 104, ID: "invalid", Err: fmt.Errorf("invalid salary")},
+		Record{Line: 105, ID: "out-of-range", Salary: 60000, Year: 1990},
+	)
+
+	results, summary := processor.Process(context.Background(), records)
+
+	if len(results) != len(records) {
+		t.Fatalf("expected %d results but got %d", len(records), len(results))
+	}
+	for i, result := range results {
+		if result.ID != records[i].ID {
+			t.Fatalf("expected results in input order but got %s at %d", result.ID, i)
+		}
+	}
+	if results[0].Tax != 7000 || len(results[0].Breakdown) != 2 {
+		t.Errorf("expected tax 7000 over 2 brackets but got %+v", results[0])
+	}
+	if results[100].Error != "" || results[100].EffectiveRate != 0 {
+		t.Errorf("expected a zero salary to owe no tax but got %+v", results[100])
+	}
+	if results[101].Error == "" || results[102].Error == "" {
+		t.Errorf("expected errors for the missing year and the invalid row")
+	}
+	if results[103].Code != models.CodeValidation || records[103].Err != nil {
+		t.Errorf("expected the out of range year to fail validation without changing the input but got %+v", results[103])
+	}
+
+	for path, count := range requests {
+		if count != 1 {
+			t.Errorf("expected %s to be fetched once but got %d", path, count)
+		}
+	}
+	if len(requests) != 4 { // Years failing validation are not fetched
+		t.Errorf("expected 4 distinct years to be fetched but got %v", requests)
+	}
+
+	if summary.Rows != 104 || summary.Succeeded != 101 || summary.Failed != 3 {
+		t.Errorf("unexpected counts %+v", summary)
+	}
+	if summary.TotalTax != 700000 || summary.TotalSalary != 6000000 {
+		t.Errorf("expected totals 6000000/700000 but got %v/%v", summary.TotalSalary, summary.TotalTax)
+	}
+
+	t.Run("Rows follow includeTaxYear", func(t *testing.T) {
+		mu.Lock()
+		clear(requests)
+		mu.Unlock()
+
+		processor := NewProcessor(calculator, Options{BaseURL: server.URL, Workers: 1, Rules: rules})
+		results, _ := processor.Process(context.Background(), []Record{{Line: 2, ID: "e1", Salary: 60000.33, Year: 2019}})
+		if results[0].Error != "" || results[0].Year != 0 || results[0].Tax != 7000.07 {
+			t.Errorf("expected the default year's tax rounded to cents but got %+v", results[0])
+		}
+		if len(requests) != 1 || requests["/"] != 1 {
+			t.Errorf("expected only the default year to be fetched but got %v", requests)
+		}
+	})
+
+	t.Run("Per-jurisdiction brackets only", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"jurisdictions":[{"code":"US","tax_brackets":[{"min":0,"rate":0.1}]}]}`)
+		}))
+		defer server.Close()
+
+		processor := NewProcessor(calculator, Options{BaseURL: server.URL, Workers: 1, Rules: rules})
+		results, summary := processor.Process(context.Background(), []Record{{Line: 2, ID: "e1", Salary: 60000, Year: 2022}})
+		if results[0].Code != models.CodeUpstreamError || summary.Failed != 1 {
+			t.Errorf("expected the item to fail with %q but got %+v", models.CodeUpstreamError, results[0])
+		}
+	})
+}
+
+func TestWriter(t *testing.T) {
+	result := Result{
+		Line: 2, ID: "e1", Salary: 60000, Year: 2022, Tax: 7000, EffectiveRate: 0.117,
+		Breakdown: []models.BracketTax{
+			{Min: 0, Max: 50000, Rate: 0.1, TaxableAmount: 50000, Tax: 5000},
+			{Min: 50000, Rate: 0.2, TaxableAmount: 10000, Tax: 2000},
+		},
+	}
+
+	var csvOut bytes.Buffer
+	writer, _ := NewWriter(&csvOut, FormatCSV, true)
+	writer.Write(result)
+	writer.Write(Result{Line: 3, ID: "e2", Error: "invalid salary"})
+	writer.Flush()
+
+	expected := "line,id,salary,year,tax,effective_rate,breakdown,error\n" +
+		"2,e1,60000,2022,7000,0.117,0-50000@0.1=5000;50000-@0.2=2000,\n" +
+		"3,e2,0,,0,0,,invalid salary\n"
+	if csvOut.String() != expected {
+		t.Errorf("expected CSV:\n%s\nbut got:\n%s", expected, csvOut.String())
+	}
+
+	var jsonOut bytes.Buffer
+	writer, _ = NewWriter(&jsonOut, FormatJSONL, false)
+	writer.Write(result)
+	if !strings.Contains(jsonOut.String(), `"effective_rate":0.117`) || strings.Count(jsonOut.String(), "\n") != 1 {
+		t.Errorf("unexpected JSON Lines output %q", jsonOut.String())
+	}
+}
@@ -0,0 +1,383 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format is a batch file format
+type Format string
+
+// Supported formats
+const (
+	FormatCSV   Format = "csv"   // Header row naming the id, salary and (optional) year columns
+	FormatJSON  Format = "json"  // JSON array of objects: [{"id":"e1","salary":85000,"year":2022}]
+	FormatJSONL Format = "jsonl" // One JSON object per line: {"id":"e1","salary":85000,"year":2022}
+)
+
+// ErrTooManyRecords is returned when the input holds more records than allowed
+var ErrTooManyRecords = errors.New("too many records")
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "csv":
+		return FormatCSV, nil
+	case "json":
+		return FormatJSON, nil
+	case "jsonl", "ndjson":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("unknown batch format %q (expected csv, json or jsonl)", name)
+	}
+}
+
+// FormatFromPath infers the format from a file extension, defaulting to CSV
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return FormatCSV
+	}
+}
+
+// ReadRecords reads every record from r, failing with ErrTooManyRecords once more
+// than limit records were read (0 means no limit). Rows that cannot be parsed are
+// returned with Err set so they are reported alongside the others; an error is only
+// returned when the input as a whole is unreadable (e.g. a missing CSV column or
+// malformed JSON array).
+func ReadRecords(r io.Reader, format Format, limit int) ([]Record, error) {
+	var next func() (Record, error)
+	switch format {
+	case FormatCSV:
+		next = newCSVReader(r)
+	case FormatJSON:
+		next = newJSONReader(r)
+	case FormatJSONL:
+		next = newJSONLReader(r)
+	default:
+		return nil, fmt.Errorf("unknown batch format %q", format)
+	}
+
+	var records []Record
+	for {
+		record, err := next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(records) == limit {
+			return nil, fmt.Errorf("%w: the limit is %d", ErrTooManyRecords, limit)
+		}
+		records = append(records, record)
+	}
+}
+
+// newCSVReader returns a function reading records from CSV with a header row
+func newCSVReader(r io.Reader) func() (Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Short rows are reported per row rather than failing the file
+	reader.TrimLeadingSpace = true
+
+	var columns map[string]int
+	return func() (Record, error) {
+		if columns == nil {
+			header, err := reader.Read()
+			if err == io.EOF {
+				return Record{}, io.EOF
+			}
+			if err != nil {
+				return Record{}, fmt.Errorf("failed to read CSV header: %w", err)
+			}
+
+			columns = map[string]int{}
+			for i, name := range header {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			for _, required := range []string{"id", "salary"} {
+				if _, ok := columns[required]; !ok {
+					return Record{}, fmt.Errorf("CSV header is missing the %q column (expected id,salary,year)", required)
+				}
+			}
+		}
+
+		row, err := reader.Read()
+		if err == io.EOF {
+			return Record{}, io.EOF
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return Record{}, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			return Record{Line: parseErr.Line, Err: fmt.Errorf("invalid CSV row: %v", parseErr.Err)}, nil
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if column, ok := columns[name]; ok && column < len(row) {
+				return strings.TrimSpace(row[column])
+			}
+			return ""
+		}
+		return parseRecord(line, field("id"), field("salary"), field("year")), nil
+	}
+}
+
+// jsonRecord is the JSON representation of a record; the id may be a string or a number
+type jsonRecord struct {
+	ID     json.RawMessage `json:"id"`
+	Salary json.Number     `json:"salary"`
+	Year   json.Number     `json:"year"`
+}
+
+// decodeJSONRecord decodes and validates one JSON item
+func decodeJSONRecord(line int, data []byte) Record {
+	var raw jsonRecord
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return Record{Line: line, Err: fmt.Errorf("invalid JSON: %v", err)}
+	}
+
+	id := string(raw.ID)
+	var quoted string
+	if json.Unmarshal(raw.ID, &quoted) == nil {
+		id = quoted
+	}
+	return parseRecord(line, id, raw.Salary.String(), raw.Year.String())
+}
+
+// newJSONReader returns a function reading records from a JSON array. Unlike
+// the line-based formats a malformed item cannot be skipped, so it fails the input.
+func newJSONReader(r io.Reader) func() (Record, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	item := 0
+	return func() (Record, error) {
+		if item == 0 {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				return Record{}, io.EOF
+			}
+			if err != nil {
+				return Record{}, fmt.Errorf("invalid JSON: %w", err)
+			}
+			if delim, ok := token.(json.Delim); !ok || delim != '[' {
+				return Record{}, fmt.Errorf("invalid JSON: expected an array of items")
+			}
+		}
+		if !decoder.More() {
+			if _, err := decoder.Token(); err != nil {
+				return Record{}, fmt.Errorf("invalid JSON: %w", err)
+			}
+			return Record{}, io.EOF
+		}
+
+		// Only malformed JSON fails the input; an item of the wrong shape is reported on its own
+		item++
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return Record{}, fmt.Errorf("invalid JSON in item %d: %w", item, err)
+		}
+		return decodeJSONRecord(item, raw), nil
+	}
+}
+
+// newJSONLReader returns a function reading records from JSON Lines, skipping blank lines
+func newJSONLReader(r io.Reader) func() (Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	return func() (Record, error) {
+		for scanner.Scan() {
+			line++
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+
+			return decodeJSONRecord(line, text), nil
+		}
+		if err := scanner.Err(); err != nil {
+			return Record{}, fmt.Errorf("failed to read JSON Lines: %w", err)
+		}
+		return Record{}, io.EOF
+	}
+}
+
+// parseRecord validates the fields of one row
+func parseRecord(line int, id, salaryStr, yearStr string) Record {
+	record := Record{Line: line, ID: id}
+
+	if salaryStr == "" {
+		record.Err = fmt.Errorf("salary is required")
+		return record
+	}
+	salary, err := strconv.ParseFloat(salaryStr, 64)
+	if err != nil {
+		record.Err = fmt.Errorf("invalid salary %q", salaryStr)
+		return record
+	}
+	if salary < 0 {
+		record.Err = fmt.Errorf("salary must not be negative")
+		return record
+	}
+	record.Salary = salary
+
+	if yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil || year < 0 {
+			record.Err = fmt.Errorf("invalid year %q", yearStr)
+			return record
+		}
+		record.Year = year
+	}
+
+	return record
+}
+
+// Writer writes results in a batch format
+type Writer interface {
+	Write(result Result) error
+	Flush() error // Completes the output; called once after the last result
+}
+
+// NewWriter creates a writer for the given format. The CSV breakdown column lists
+// each bracket as "min-max@rate=tax", separated by semicolons.
+func NewWriter(w io.Writer, format Format, breakdown bool) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w), breakdown: breakdown}, nil
+	case FormatJSON:
+		return &jsonWriter{writer: w}, nil
+	case FormatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown batch format %q", format)
+	}
+}
+
+// csvWriter writes results as CSV with a header row
+type csvWriter struct {
+	writer        *csv.Writer
+	breakdown     bool
+	headerWritten bool
+}
+
+// Write writes one result row, preceded by the header on the first call
+func (c *csvWriter) Write(result Result) error {
+	if !c.headerWritten {
+		header := []string{"line", "id", "salary", "year", "tax", "effective_rate"}
+		if c.breakdown {
+			header = append(header, "breakdown")
+		}
+		if err := c.writer.Write(append(header, "error")); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	year := ""
+	if result.Year > 0 {
+		year = strconv.Itoa(result.Year)
+	}
+	row := []string{
+		strconv.Itoa(result.Line),
+		result.ID,
+		formatAmount(result.Salary),
+		year,
+		formatAmount(result.Tax),
+		formatFloat(result.EffectiveRate),
+	}
+	if c.breakdown {
+		brackets := make([]string, len(result.Breakdown))
+		for i, bracket := range result.Breakdown {
+			max := ""
+			if bracket.Max != 0 {
+				max = formatAmount(bracket.Max)
+			}
+			brackets[i] = fmt.Sprintf("%s-%s@%s=%s", formatAmount(bracket.Min), max, formatFloat(bracket.Rate), formatAmount(bracket.Tax))
+		}
+		row = append(row, strings.Join(brackets, ";"))
+	}
+	return c.writer.Write(append(row, result.Error))
+}
+
+// Flush writes any buffered rows
+func (c *csvWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// jsonWriter writes results as a JSON array, one item per line
+type jsonWriter struct {
+	writer  io.Writer
+	written int
+}
+
+// Write writes one array item, preceded by the opening bracket or a separator
+func (j *jsonWriter) Write(result Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if j.written == 0 {
+		prefix = "[\n"
+	}
+	j.written++
+	_, err = fmt.Fprintf(j.writer, "%s%s", prefix, data)
+	return err
+}
+
+// Flush closes the array
+func (j *jsonWriter) Flush() error {
+	if j.written == 0 {
+		_, err := io.WriteString(j.writer, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(j.writer, "\n]\n")
+	return err
+}
+
+// jsonlWriter writes results as JSON Lines
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+// Write writes one result line
+func (j *jsonlWriter) Write(result Result) error {
+	return j.encoder.Encode(result)
+}
+
+// Flush is a no-op; every line is written immediately
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
+
+// formatFloat formats rates without trailing zeros
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatAmount formats money rounded to cents, without trailing zeros
+func formatAmount(value float64) string {
+	return formatFloat(math.Round(value*100) / 100)
+}
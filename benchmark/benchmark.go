@@ -205,7 +205,7 @@ func worker(config Config, jobs <-chan string, results chan<- RequestResult, wg
 		switch method {
 		case "GET":
 			// Build GET request with URL parameters
-			reqURL := fmt.Sprintf("%s/income-salary?salary=%d&year=%d", config.Host, salary, year)
+			reqURL := fmt.Sprintf("%s/v1/income-salary?salary=%d&year=%d", config.Host, salary, year)
 			req, err = http.NewRequest("GET", reqURL, nil)
 		case "POST":
 			// Build POST request with form data
@@ -213,7 +213,7 @@ func worker(config Config, jobs <-chan string, results chan<- RequestResult, wg
 			data.Set("salary", fmt.Sprintf("%d", salary))
 			data.Set("year", fmt.Sprintf("%d", year))
 
-			req, err = http.NewRequest("POST", config.Host+"/income-salary", strings.NewReader(data.Encode()))
+			req, err = http.NewRequest("POST", config.Host+"/v1/income-salary", strings.NewReader(data.Encode()))
 			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		}
 
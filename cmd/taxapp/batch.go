@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"pulsegrade/test1/batch"
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/validation"
+)
+
+// runBatch implements "taxapp batch": it calculates tax for every row of a CSV or JSON Lines file
+func runBatch(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("batch", "Calculate tax for every row (id,salary,year) of a CSV or JSON Lines file", stderr)
+	configFlags := addConfigFlags(flags)
+	input := flags.String("input", "-", "Input file (- for stdin)")
+	output := flags.String("output", "-", "Output file (- for stdout)")
+	inputFormat := flags.String("input-format", "", "Input format, csv, json or jsonl (default: from the file extension, csv for stdin)")
+	outputFormat := flags.String("output-format", "", "Output format, csv, json or jsonl (default: from the file extension, else the input format)")
+	breakdown := flags.Bool("breakdown", false, "Include the tax owed in each bracket")
+	workers := flags.Int("workers", 0, "Parallel calculations (default: number of CPUs)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	inFormat, err := batchFormat(*inputFormat, *input, "")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	outFormat, err := batchFormat(*outputFormat, *output, inFormat)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	cfg, code, ok := configFlags.load(true, stderr)
+	if !ok {
+		return code
+	}
+
+	// Read every row up front so each year's brackets are fetched only once
+	in, err := openInput(*input)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	records, err := batch.ReadRecords(in, inFormat, 0)
+	in.Close()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	processor := batch.NewProcessor(newOfflineCalculator(cfg), batch.Options{
+		BaseURL:        cfg.TaxCalcBaseURL,
+		IncludeTaxYear: cfg.IncludeTaxYear,
+		Workers:        *workers,
+		Breakdown:      *breakdown,
+		Rules:          validation.New(cfg.Validation),
+	})
+	results, summary := processor.Process(context.Background(), records)
+
+	out, err := openOutput(*output, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	defer out.Close()
+
+	writer, err := batch.NewWriter(out, outFormat, *breakdown)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	for _, result := range results {
+		if err := writer.Write(result); err != nil {
+			fmt.Fprintf(stderr, "Failed to write results: %v\n", err)
+			return exitError
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		fmt.Fprintf(stderr, "Failed to write results: %v\n", err)
+		return exitError
+	}
+
+	// The summary goes to stderr so it never mixes with results written to stdout
+	printSummary(stderr, summary)
+	if summary.Failed > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// batchFormat returns the explicitly requested format, or infers it from the
+// path, falling back to the given default for stdin/stdout
+func batchFormat(name, path string, fallback batch.Format) (batch.Format, error) {
+	if name != "" {
+		return batch.ParseFormat(name)
+	}
+	if path == "-" {
+		if fallback != "" {
+			return fallback, nil
+		}
+		return batch.FormatCSV, nil
+	}
+	return batch.FormatFromPath(path), nil
+}
+
+// openInput opens the input file, or stdin for "-"
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %v", err)
+	}
+	return file, nil
+}
+
+// nopWriteCloser adds a no-op Close to stdout
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// openOutput creates the output file, or returns stdout for "-"
+func openOutput(path string, stdout io.Writer) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopWriteCloser{stdout}, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output: %v", err)
+	}
+	return file, nil
+}
+
+// printSummary writes the totals of a batch
+func printSummary(w io.Writer, summary batch.Summary) {
+	years := make([]string, len(summary.Years))
+	for i, year := range summary.Years {
+		years[i] = metrics.TaxYearLabel(year)
+	}
+
+	fmt.Fprintf(w, "Rows:           %d (%d succeeded, %d failed)\n", summary.Rows, summary.Succeeded, summary.Failed)
+	fmt.Fprintf(w, "Tax years:      %v\n", years)
+	fmt.Fprintf(w, "Total salary:   %.2f\n", summary.TotalSalary)
+	fmt.Fprintf(w, "Total tax:      %.2f\n", summary.TotalTax)
+	fmt.Fprintf(w, "Effective rate: %.1f%%\n", summary.EffectiveRate*100)
+	fmt.Fprintf(w, "Duration:       %.0fms\n", summary.DurationMs)
+}
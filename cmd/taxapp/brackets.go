@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/services"
+)
+
+// runBrackets implements "taxapp brackets": it prints the brackets of a tax year
+func runBrackets(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("brackets", "Fetch and print the tax brackets of a year from the tax calculator", stderr)
+	configFlags := addConfigFlags(flags)
+	year := flags.Int("year", 0, "Tax year (default: the upstream default, or the current year when includeTaxYear is set)")
+	asJSON := flags.Bool("json", false, "Print the brackets as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	cfg, code, ok := configFlags.load(true, stderr)
+	if !ok {
+		return code
+	}
+
+	// The top-level schedule is printed, so per-jurisdiction data is rejected like in the API
+	taxYear := services.ResolveTaxYear(cfg.IncludeTaxYear || *year > 0, *year)
+	taxData, err := newOfflineCalculator(cfg).FetchTaxData(context.Background(), services.TaxDataURL(cfg.TaxCalcBaseURL, taxYear), taxYear)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to fetch tax brackets: %v\n", err)
+		return exitError
+	}
+	brackets := taxData.TaxBrackets
+
+	if *asJSON {
+		return writeJSON(stdout, stderr, brackets)
+	}
+
+	fmt.Fprintf(stdout, "Tax year: %s\n\n", metrics.TaxYearLabel(taxYear))
+	table := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "Min\tMax\tRate\t")
+	for _, bracket := range brackets {
+		max := "-"
+		if bracket.Max > 0 {
+			max = fmt.Sprintf("%.2f", bracket.Max)
+		}
+		fmt.Fprintf(table, "%.2f\t%s\t%.2f%%\t\n", bracket.Min, max, bracket.Rate*100)
+	}
+	table.Flush()
+	return exitOK
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+	"pulsegrade/test1/validation"
+)
+
+// runCalc implements "taxapp calc": it calculates the tax of a salary without starting the server
+func runCalc(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("calc", "Calculate tax for a salary using brackets from the tax calculator", stderr)
+	configFlags := addConfigFlags(flags)
+	salary := flags.Float64("salary", 0, "Annual salary (required)")
+	year := flags.Int("year", 0, "Tax year (default: the upstream default, or the current year when includeTaxYear is set)")
+	deductions := flags.String("deductions", "", "Pre-tax deductions as type:amount pairs, e.g. retirement:5000,union_dues:600")
+	dependents := flags.Int("dependents", 0, "Dependents claimed for the per-dependent credit")
+	jurisdiction := flags.String("jurisdiction", "", "Jurisdiction code whose schedules are combined, e.g. US-CA (default: the upstream default)")
+	asJSON := flags.Bool("json", false, "Print the result as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !isFlagSet(flags, "salary") || *salary < 0 {
+		fmt.Fprintln(stderr, "A non-negative --salary is required")
+		flags.Usage()
+		return exitUsage
+	}
+
+	cfg, code, ok := configFlags.load(true, stderr)
+	if !ok {
+		return code
+	}
+	rules := validation.New(cfg.Validation)
+	fields := rules.Check(*salary, *year)
+	claims := models.Claims{Dependents: *dependents}
+	if *deductions != "" {
+		var deductionFields []models.FieldError
+		claims.Deductions, deductionFields = rules.ParseDeductions(*deductions)
+		fields = append(fields, deductionFields...)
+	}
+	if field := rules.Dependents(*dependents); field != nil {
+		fields = append(fields, *field)
+	}
+	*jurisdiction = strings.ToUpper(*jurisdiction)
+	if *jurisdiction != "" {
+		if field := rules.Jurisdiction(*jurisdiction); field != nil {
+			fields = append(fields, *field)
+		}
+	}
+	if len(fields) > 0 {
+		for _, field := range fields {
+			fmt.Fprintln(stderr, field.Message)
+		}
+		return exitUsage
+	}
+
+	calculator := newOfflineCalculator(cfg)
+	taxData, taxYear, err := fetchTaxData(calculator, cfg, *jurisdiction, *year)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if disallowed := services.DisallowedDeductions(claims, taxData); len(disallowed) > 0 {
+		fmt.Fprintf(stderr, "Tax year %s does not allow deductions: %s\n", metrics.TaxYearLabel(taxYear), strings.Join(disallowed, ", "))
+		return exitUsage
+	}
+
+	combined := calculator.CalculateCombined(*salary, claims, taxData)
+	net, marginal := combined.Total, combined.Marginal
+	if len(taxData.Jurisdictions) == 0 {
+		combined.Jurisdictions = nil // A single schedule is described by the totals
+	}
+
+	if *asJSON {
+		return writeJSON(stdout, stderr, models.Response{
+			Salary:              *salary,
+			Deductions:          net.Deductions,
+			TaxableIncome:       net.TaxableIncome,
+			GrossTax:            net.GrossTax,
+			Credits:             net.Credits,
+			NetTax:              net.NetTax,
+			Tax:                 net.NetTax,
+			EffectiveRate:       net.EffectiveRate,
+			MarginalRate:        marginal.Rate,
+			Bracket:             marginal.Bracket,
+			IncomeToNextBracket: marginal.IncomeToNextBracket,
+			TaxOnNextDollar:     marginal.TaxOnNextDollar,
+			TaxOnNextThousand:   marginal.TaxOnNextThousand,
+			Jurisdiction:        *jurisdiction,
+			Jurisdictions:       combined.Jurisdictions,
+		})
+	}
+	fmt.Fprintf(stdout, "Tax year:       %s\n", metrics.TaxYearLabel(taxYear))
+	if *jurisdiction != "" {
+		fmt.Fprintf(stdout, "Jurisdiction:   %s\n", *jurisdiction)
+	}
+	fmt.Fprintf(stdout, "Salary:         %.2f\n", *salary)
+	if net.Deductions > 0 || net.Credits > 0 {
+		fmt.Fprintf(stdout, "Deductions:     %.2f\n", net.Deductions)
+		fmt.Fprintf(stdout, "Taxable income: %.2f\n", net.TaxableIncome)
+		fmt.Fprintf(stdout, "Gross tax:      %.2f\n", net.GrossTax)
+		fmt.Fprintf(stdout, "Credits:        %.2f\n", net.Credits)
+	}
+	for _, part := range combined.Jurisdictions {
+		fmt.Fprintf(stdout, "  %-13s %.2f (marginal rate %.1f%%)\n", part.Code+":", part.NetTax, part.MarginalRate*100)
+	}
+	fmt.Fprintf(stdout, "Tax:            %.2f\n", net.NetTax)
+	fmt.Fprintf(stdout, "Effective rate: %.1f%%\n", net.EffectiveRate*100)
+	fmt.Fprintf(stdout, "Marginal rate:  %.1f%%\n", marginal.Rate*100)
+	if marginal.IncomeToNextBracket != nil {
+		fmt.Fprintf(stdout, "Next bracket:   in %.2f\n", *marginal.IncomeToNextBracket)
+	}
+	fmt.Fprintf(stdout, "Tax on +1000:   %.2f\n", marginal.TaxOnNextThousand)
+	return exitOK
+}
+
+// newOfflineCalculator creates a calculator for one-shot commands: a single
+// upstream call needs neither the circuit breaker nor metrics
+func newOfflineCalculator(cfg models.Config) *services.TaxCalculator {
+	return services.NewTaxCalculatorWithFullConfig(cfg.Environment, false, cfg.CircuitBreaker, metrics.NewNoop())
+}
+
+// fetchTaxData retrieves the brackets and allowances of a jurisdiction (empty for
+// the default) for the requested year (0 for the default) from the configured
+// tax calculator, returning the year actually requested. An explicit
+// jurisdiction is always honored, like an explicit year.
+func fetchTaxData(calculator *services.TaxCalculator, cfg models.Config, jurisdiction string, requestedYear int) (*models.TaxCalculatorResponse, int, error) {
+	// An explicit year is always honored; otherwise follow the includeTaxYear setting like the API does
+	taxYear := services.ResolveTaxYear(cfg.IncludeTaxYear || requestedYear > 0, requestedYear)
+
+	taxData, err := calculator.FetchJurisdictionTaxData(context.Background(), cfg.TaxCalcBaseURL, jurisdiction, taxYear)
+	if err != nil {
+		return nil, taxYear, fmt.Errorf("failed to fetch tax brackets: %v", err)
+	}
+	return taxData, taxYear, nil
+}
+
+// writeJSON prints v as indented JSON
+func writeJSON(stdout, stderr io.Writer, v interface{}) int {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintf(stderr, "Failed to write JSON: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
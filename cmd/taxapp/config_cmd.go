@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"pulsegrade/test1/config"
+)
+
+// runConfig implements "taxapp config <subcommand>"
+func runConfig(args []string, stdout, stderr io.Writer) int {
+	usage := func(w io.Writer) {
+		fmt.Fprintln(w, "Usage: taxapp config <subcommand> [flags]")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Subcommands:")
+		fmt.Fprintln(w, "  validate   Load the configuration and report every invalid setting")
+	}
+
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(args[1:], stdout, stderr)
+	case "-h", "--help", "help":
+		usage(stdout)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "Unknown config subcommand %q\n\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+}
+
+// runConfigValidate implements "taxapp config validate"
+func runConfigValidate(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("config validate", "Load the configuration and report every invalid setting", stderr)
+	configFlags := addConfigFlags(flags)
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	cfg, code, ok := configFlags.load(true, stderr)
+	if !ok {
+		return code
+	}
+
+	if err := config.Validate(cfg); err != nil {
+		fmt.Fprintf(stderr, "Invalid configuration for environment %s:\n%v\n", configFlags.environment(), err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "Configuration for environment %s is valid\n", configFlags.environment())
+	return exitOK
+}
@@ -1,56 +1,194 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
+	"strings"
 
 	"pulsegrade/test1/config"
-	"pulsegrade/test1/handlers"
-	"pulsegrade/test1/logger"
-	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+)
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+// Exit codes shared by all commands
+const (
+	exitOK    = 0 // Success
+	exitError = 1 // The command failed (upstream error, invalid configuration, ...)
+	exitUsage = 2 // Invalid command line
 )
 
+// command is a taxapp subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+// commands lists the subcommands in the order shown by help
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "serve", summary: "Run the HTTP API and admin listener (default)", run: runServe},
+		{name: "calc", summary: "Calculate tax for a salary offline", run: runCalc},
+		{name: "batch", summary: "Calculate tax for every row of a CSV or JSON Lines file", run: runBatch},
+		{name: "brackets", summary: "Fetch and print the tax brackets of a year", run: runBrackets},
+		{name: "config", summary: "Inspect configuration (config validate)", run: runConfig},
+		{name: "version", summary: "Print build information", run: runVersion},
+		{name: "help", summary: "Show help for a command", run: runHelp},
+	}
+}
+
 func main() {
-	// Get environment from command line args
-	env := "dev" // Default to dev
-	if len(os.Args) > 1 {
-		env = os.Args[1]
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches to the subcommand named by the first argument and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return runServe(nil, stdout, stderr)
+	}
+
+	name := args[0]
+	switch {
+	case name == "-h" || name == "--help":
+		printUsage(stdout)
+		return exitOK
+	case strings.HasPrefix(name, "-"):
+		// Flags without a command are serve flags
+		return runServe(args, stdout, stderr)
+	}
+
+	if cmd := findCommand(name); cmd != nil {
+		return cmd.run(args[1:], stdout, stderr)
+	}
+
+	// Older deployments run "taxapp <env>"; treat a bare environment name as "serve --env <env>"
+	if len(args) == 1 && isEnvironmentName(name) {
+		return runServe([]string{"--env", name}, stdout, stderr)
+	}
+
+	fmt.Fprintf(stderr, "Unknown command %q\n\n", name)
+	printUsage(stderr)
+	return exitUsage
+}
+
+// findCommand returns the subcommand with the given name, or nil
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
 	}
+	return nil
+}
 
-	// Log with standard log package until logger is configured
-	fmt.Printf("===> Starting application with environment: %v\n", env)
+// isEnvironmentName reports whether name looks like an environment (dev, prod, ...)
+// rather than a mistyped command
+func isEnvironmentName(name string) bool {
+	switch name {
+	case "dev", "test", "staging", "prod":
+		return true
+	}
+	return false
+}
 
-	// Load configuration from environment variables
-	cfg := config.Load(env)
+// printUsage writes the top-level help
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: taxapp <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"taxapp help <command>\" for the flags of a command.")
+	fmt.Fprintln(w, "Exit codes: 0 success, 1 failure, 2 invalid usage.")
+}
 
-	// Logger is now configured based on settings from config
-	logger.Info("===> Application starting with environment: %v", env)
-	logger.Debug("===> Loaded configuration: %+v", cfg)
+// runHelp implements "taxapp help [command]"
+func runHelp(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stdout)
+		return exitOK
+	}
 
-	// Create handlers
-	incomeSalaryHandler := handlers.NewIncomeSalaryHandler(cfg)
+	cmd := findCommand(args[0])
+	if cmd == nil || cmd.name == "help" {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+	return cmd.run(append(args[1:], "-h"), stdout, stderr)
+}
 
-	// Create a new ServeMux for route handling
-	mux := http.NewServeMux()
+// newFlagSet creates the flag set of a subcommand with consistent help output
+func newFlagSet(name, summary string, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: taxapp %s [flags]\n\n%s\n\nFlags:\n", name, summary)
+		flags.PrintDefaults()
+	}
+	return flags
+}
 
-	// Setup application routes
-	mux.HandleFunc("/income-salary", incomeSalaryHandler.Handle)
+// parseFlags parses args, returning false with the exit code to use when the
+// command should not run (help was requested or the flags are invalid)
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "Unexpected arguments: %s\n\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
 
-	// Expose Prometheus metrics endpoint
-	mux.Handle("/metrics", promhttp.Handler())
+// isFlagSet reports whether the named flag was given on the command line
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
 
-	// Wrap the ServeMux with the metrics middleware
-	handler := metrics.MetricsMiddleware(mux, env)
+// configFlags are the flags selecting the configuration, shared by all commands that load it
+type configFlags struct {
+	env  *string
+	file *string
+}
 
-	// Log server startup
-	logger.Info("Server started on port %s in %s environment", cfg.Port, env)
-	logger.Info("Metrics available at http://localhost:%s/metrics", cfg.Port)
+// addConfigFlags registers --env and --config on flags
+func addConfigFlags(flags *flag.FlagSet) *configFlags {
+	return &configFlags{
+		env:  flags.String("env", "dev", "Environment whose configuration is loaded (dev, prod, ...)"),
+		file: flags.String("config", "", "Config file to load instead of the built-in config.yaml/config.<env>.yaml"),
+	}
+}
+
+// environment returns the selected environment name
+func (c *configFlags) environment() string {
+	return *c.env
+}
 
-	// Start the server
-	if err := http.ListenAndServe(":"+cfg.Port, handler); err != nil {
-		logger.Fatal("Server failed to start: %v", err)
+// load reads the selected configuration. Quiet loading only logs errors, so the
+// output of commands that print results is not mixed with log lines.
+func (c *configFlags) load(quiet bool, stderr io.Writer) (models.Config, int, bool) {
+	cfg, err := config.LoadWithOptions(config.Options{Environment: *c.env, File: *c.file, Quiet: quiet})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return models.Config{}, exitError, false
 	}
+	return cfg, exitOK, true
 }
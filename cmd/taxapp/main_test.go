@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+)
+
+// writeConfig writes a config file pointing at baseURL and returns its path
+func writeConfig(t *testing.T, baseURL string, extra string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "taxapp.yaml")
+	content := fmt.Sprintf("taxCalculator:\n  baseUrl: %s\nlogging:\n  level: ERROR\n%s", baseURL, extra)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+// newUpstream serves fixed brackets, recording the requested paths
+func newUpstream(t *testing.T, paths *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.URL.Path)
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":50000,"rate":0.2}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "Help", args: []string{"help"}, code: exitOK},
+		{name: "Help flag", args: []string{"-h"}, code: exitOK},
+		{name: "Command help", args: []string{"help", "calc"}, code: exitOK},
+		{name: "Unknown command", args: []string{"bogus"}, code: exitUsage},
+		{name: "Unknown flag", args: []string{"version", "--bogus"}, code: exitUsage},
+		{name: "Missing salary", args: []string{"calc"}, code: exitUsage},
+		{name: "Unexpected argument", args: []string{"brackets", "2022"}, code: exitUsage},
+		{name: "Missing config subcommand", args: []string{"config"}, code: exitUsage},
+		{name: "Missing config file", args: []string{"config", "validate", "--config", "does-not-exist.yaml"}, code: exitError},
+		{name: "Version", args: []string{"version"}, code: exitOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tc.args, &stdout, &stderr); code != tc.code {
+				t.Errorf("expected exit code %d but got %d (stderr: %s)", tc.code, code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestCalc(t *testing.T) {
+	var paths []string
+	upstream := newUpstream(t, &paths)
+	configFile := writeConfig(t, upstream.URL, "")
+
+	t.Run("Text output", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"calc", "--config", configFile, "--salary", "60000"}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("expected success but got %d (stderr: %s)", code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "Tax:            7000.00") {
+			t.Errorf("expected tax 7000.00 in output but got:\n%s", stdout.String())
+		}
+	})
+
+	t.Run("JSON output with year", func(t *testing.T) {
+		paths = nil
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"calc", "--config", configFile, "--salary", "60000", "--year", "2021", "--json"}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("expected success but got %d (stderr: %s)", code, stderr.String())
+		}
+
+		var response models.Response
+		if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+			t.Fatalf("expected JSON output but got %q: %v", stdout.String(), err)
+		}
+		if response.Tax != 7000 || response.EffectiveRate != 0.117 {
+			t.Errorf("expected tax 7000 at 0.117 but got %v at %v", response.Tax, response.EffectiveRate)
+		}
+		if len(paths) != 1 || paths[0] != "/tax-year/2021" {
+			t.Errorf("expected the 2021 brackets to be requested but got %v", paths)
+		}
+	})
+
+	t.Run("Upstream unavailable", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		configFile := writeConfig(t, "http://127.0.0.1:1", "")
+		if code := run([]string{"calc", "--config", configFile, "--salary", "60000"}, &stdout, &stderr); code != exitError {
+			t.Errorf("expected exit code %d but got %d", exitError, code)
+		}
+	})
+}
+
+func TestBrackets(t *testing.T) {
+	var paths []string
+	upstream := newUpstream(t, &paths)
+	configFile := writeConfig(t, upstream.URL, "")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"brackets", "--config", configFile, "--json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected success but got %d (stderr: %s)", code, stderr.String())
+	}
+
+	var brackets []models.TaxBracket
+	if err := json.Unmarshal(stdout.Bytes(), &brackets); err != nil {
+		t.Fatalf("expected JSON output but got %q: %v", stdout.String(), err)
+	}
+	if len(brackets) != 2 || brackets[1].Rate != 0.2 {
+		t.Errorf("expected the upstream brackets but got %+v", brackets)
+	}
+	if len(paths) != 1 || paths[0] != "/" {
+		t.Errorf("expected the default year to be requested but got %v", paths)
+	}
+
+	t.Run("Per-jurisdiction brackets only", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"jurisdictions":[{"code":"US","tax_brackets":[{"min":0,"rate":0.1}]}]}`)
+		}))
+		defer upstream.Close()
+
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"brackets", "--config", writeConfig(t, upstream.URL, "")}, &stdout, &stderr); code != exitError {
+			t.Errorf("expected exit code %d but got %d (stdout: %s)", exitError, code, stdout.String())
+		}
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	valid := writeConfig(t, "http://localhost:5001/tax-calculator", "")
+	if code := run([]string{"config", "validate", "--config", valid}, &stdout, &stderr); code != exitOK {
+		t.Errorf("expected a valid configuration but got %d (stderr: %s)", code, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	invalid := writeConfig(t, "localhost:5001", "accessLog:\n  format: xml\n")
+	if code := run([]string{"config", "validate", "--config", invalid}, &stdout, &stderr); code != exitError {
+		t.Fatalf("expected exit code %d but got %d", exitError, code)
+	}
+	for _, key := range []string{"taxCalculator.baseUrl", "accessLog.format"} {
+		if !strings.Contains(stderr.String(), key) {
+			t.Errorf("expected an error for %s but got:\n%s", key, stderr.String())
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var paths []string
+	upstream := newUpstream(t, &paths)
+	configFile := writeConfig(t, upstream.URL, "")
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "payroll.csv")
+	output := filepath.Join(dir, "results.jsonl")
+	os.WriteFile(input, []byte("id,salary,year\ne1,60000,2021\ne2,40000,2021\ne3,abc,2021\n"), 0600)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"batch", "--config", configFile, "--input", input, "--output", output}, &stdout, &stderr)
+	if code != exitError {
+		t.Errorf("expected exit code %d for a batch with a failed row but got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "3 (2 succeeded, 1 failed)") || !strings.Contains(stderr.String(), "Total tax:      11000.00") {
+		t.Errorf("expected summary totals but got:\n%s", stderr.String())
+	}
+
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 3 {
+		t.Errorf("expected 3 JSON lines but got:\n%s", content)
+	}
+	if len(paths) != 1 {
+		t.Errorf("expected the 2021 brackets to be fetched once but got %v", paths)
+	}
+}
+
+func TestPublicHandlerHijack(t *testing.T) {
+	// A handler behind every middleware of the serve chain can take over the connection
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /upgrade", func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack the connection: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		buf.Flush()
+	})
+
+	cfg := models.Config{AccessLog: models.AccessLogConfig{Enabled: true, SampleRate: 1}}
+	server := httptest.NewServer(publicHandler(mux, cfg, "test", metrics.NewRegistry(metrics.Options{})))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/upgrade", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("expected status 101 from the hijacked connection but got %d", resp.StatusCode)
+	}
+}
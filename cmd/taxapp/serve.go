@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"pulsegrade/test1/admin"
+	"pulsegrade/test1/api"
+	"pulsegrade/test1/config"
+	"pulsegrade/test1/handlers"
+	"pulsegrade/test1/health"
+	"pulsegrade/test1/lifecycle"
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/tlsconfig"
+	"pulsegrade/test1/tracing"
+	"pulsegrade/test1/version"
+)
+
+// runServe implements "taxapp serve": it runs the HTTP API until SIGINT/SIGTERM
+func runServe(args []string, stdout, stderr io.Writer) int {
+	startTime := time.Now()
+
+	flags := newFlagSet("serve", "Run the HTTP API and admin listener", stderr)
+	configFlags := addConfigFlags(flags)
+	port := flags.String("port", "", "Public listener port (overrides the port setting)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	env := configFlags.environment()
+
+	// Log with standard log package until logger is configured
+	buildInfo := version.Get()
+	fmt.Fprintf(stdout, "===> Starting application %s (%s) with environment: %v\n", buildInfo.Version, buildInfo.Commit, env)
+
+	// Load and validate configuration
+	cfg, code, ok := configFlags.load(false, stderr)
+	if !ok {
+		return code
+	}
+	if *port != "" {
+		cfg.Port = *port
+	}
+	if err := config.Validate(cfg); err != nil {
+		fmt.Fprintf(stderr, "Invalid configuration:\n%v\n", err)
+		return exitError
+	}
+
+	// Logger is now configured based on settings from config
+	logger.Info("===> Application starting with environment: %v", env)
+	logger.Debug("===> Loaded configuration: %+v", cfg)
+
+	// Create the metrics registry exposed on /metrics
+	registry := metrics.NewRegistry(metrics.Options{
+		DurationBuckets: cfg.Metrics.DurationBuckets,
+		SizeBuckets:     cfg.Metrics.SizeBuckets,
+	})
+
+	// Publish the running build and configuration so dashboards can tell deployments apart
+	registry.RecordBuildInfo(buildInfo, startTime, env)
+	registry.RecordConfig(cfg)
+
+	// Count log lines suppressed by sampling
+	logger.SetDropHook(func(level logger.LogLevel) {
+		registry.LogLinesDropped.WithLabelValues(level.String(), env).Inc()
+	})
+
+	// Set up distributed tracing (exporter and W3C trace context propagation)
+	shutdownTracing, err := tracing.Setup(cfg.Tracing, env)
+	if err != nil {
+		logger.Error("Failed to set up tracing: %v", err)
+		logger.Close()
+		return exitError
+	}
+
+	// Create handlers
+	incomeSalaryHandler := handlers.NewIncomeSalaryHandler(cfg, registry)
+	versionHandler := handlers.NewVersionHandler(env, startTime)
+
+	// Register readiness checks for the handler's dependencies
+	checker := health.NewChecker(health.DefaultTimeout)
+	incomeSalaryHandler.RegisterHealthChecks(checker)
+
+	// Create the public API ServeMux (/v1 routes and the deprecated unversioned aliases);
+	// only business routes are served here
+	mux := api.NewMux(incomeSalaryHandler, cfg.API)
+
+	handler := publicHandler(mux, cfg, env, registry)
+
+	// Metrics, health probes, pprof and version information are served on the admin listener
+	adminMux := admin.NewMux(registry, checker, http.HandlerFunc(versionHandler.Handle), cfg.Admin.EnablePprof)
+	adminHandler := admin.AuthMiddleware(adminMux, cfg.Admin.Auth)
+
+	// Manage the server lifecycle: readiness fails first on SIGTERM, then in-flight
+	// requests drain, then background components stop (the logger last)
+	app := lifecycle.New(cfg.Server)
+	server := lifecycle.NewServer(":"+cfg.Port, handler, cfg.Server)
+	adminServer := lifecycle.NewServer(cfg.Admin.Address, adminHandler, cfg.Server)
+
+	adminTLS := cfg.Admin.TLS && !lifecycle.IsUnixSocket(cfg.Admin.Address)
+	if cfg.TLS.Enabled || adminTLS {
+		// Serve HTTPS (and HTTP/2), reloading the certificate when it is renewed
+		tlsConfig, reloader, err := tlsconfig.New(cfg.TLS)
+		if err != nil {
+			logger.Error("Failed to set up TLS: %v", err)
+			shutdownTracing(context.Background())
+			logger.Close()
+			return exitError
+		}
+		// Each server gets its own copy, as http.Server modifies the config it serves
+		// with; the copies share the reloader's GetCertificate
+		if cfg.TLS.Enabled {
+			server.TLSConfig = tlsConfig.Clone()
+		}
+		if adminTLS {
+			adminServer.TLSConfig = tlsConfig.Clone()
+		}
+		reloader.Start(time.Duration(cfg.TLS.ReloadInterval) * time.Second)
+		app.OnShutdown("certificate reloader", reloader.Stop)
+	}
+
+	app.AddServer("HTTP", server)
+	app.AddServer("Admin", adminServer)
+	app.OnDrain(checker.SetShuttingDown)
+	app.OnShutdown("tracing", lifecycle.Hook(shutdownTracing))
+	app.OnShutdown("logger", func(ctx context.Context) error {
+		logger.Close()
+		return nil
+	})
+
+	// Log server startup
+	logger.Info("Server starting on port %s in %s environment", cfg.Port, env)
+	logger.Info("Metrics, health checks and version available on the admin listener at %s", cfg.Admin.Address)
+
+	// Serve until SIGINT/SIGTERM
+	if err := app.Run(context.Background()); err != nil {
+		logger.Error("Server stopped with error: %v", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, "===> Application stopped")
+	return exitOK
+}
+
+// publicHandler wraps the public ServeMux with the request middleware chain
+func publicHandler(mux http.Handler, cfg models.Config, env string, registry *metrics.Registry) http.Handler {
+	// Wrap the ServeMux with the metrics middleware
+	handler := metrics.MetricsMiddleware(mux, env, registry)
+
+	// Write one access log line per request
+	handler = logger.AccessLogMiddleware(handler, logger.AccessLogConfig{
+		Enabled:        cfg.AccessLog.Enabled,
+		Format:         logger.AccessLogFormatFromString(cfg.AccessLog.Format),
+		SampleRate:     cfg.AccessLog.SampleRate,
+		ExcludePaths:   cfg.AccessLog.ExcludePaths,
+		TrustedProxies: cfg.AccessLog.TrustedProxies,
+	})
+
+	// Start a server span for each request
+	handler = tracing.Middleware(handler)
+
+	// Assign a request ID before anything else so every layer can log it
+	handler = logger.RequestIDMiddleware(handler)
+	return handler
+}
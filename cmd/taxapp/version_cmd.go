@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"pulsegrade/test1/version"
+)
+
+// runVersion implements "taxapp version"
+func runVersion(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("version", "Print build information", stderr)
+	asJSON := flags.Bool("json", false, "Print build information as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	info := version.Get()
+	if *asJSON {
+		return writeJSON(stdout, stderr, info)
+	}
+
+	modified := ""
+	if info.Modified {
+		modified = " (modified)"
+	}
+	fmt.Fprintf(stdout, "taxapp %s\n", info.Version)
+	fmt.Fprintf(stdout, "  commit:     %s%s\n", info.Commit, modified)
+	fmt.Fprintf(stdout, "  built:      %s\n", info.BuildDate)
+	fmt.Fprintf(stdout, "  go version: %s\n", info.GoVersion)
+	return exitOK
+}
@@ -1,16 +1,26 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"pulsegrade/test1/logger"
 	"pulsegrade/test1/models"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
+// Options controls where configuration is loaded from
+type Options struct {
+	Environment string // Environment name (dev, prod, ...); defaults to dev
+	File        string // Explicit config file, loaded instead of config.yaml and config.<env>.yaml
+	Quiet       bool   // Only log errors, for CLI commands that print results to stdout
+}
+
 // Load loads application configuration from YAML files
 func Load(env ...string) models.Config {
 	// Default to "dev" environment if not specified
@@ -19,6 +29,25 @@ func Load(env ...string) models.Config {
 		environment = env[0]
 	}
 
+	// Missing config files fall back to defaults, so there is no error to handle
+	config, _ := LoadWithOptions(Options{Environment: environment})
+	return config
+}
+
+// LoadWithOptions loads application configuration as described by opts. Without an
+// explicit file, config.yaml and config.<env>.yaml are read from the config directory
+// and missing files fall back to defaults; an explicit file that cannot be read is an error.
+func LoadWithOptions(opts Options) (models.Config, error) {
+	environment := opts.Environment
+	if environment == "" {
+		environment = "dev"
+	}
+
+	logf := log.Printf
+	if opts.Quiet {
+		logf = func(string, ...interface{}) {}
+	}
+
 	// Get the directory where config.go is located to find config files
 	_, currentFilePath, _, _ := runtime.Caller(0)
 	configDir := filepath.Dir(currentFilePath)
@@ -26,14 +55,44 @@ func Load(env ...string) models.Config {
 	v := viper.New()
 
 	// Set up Viper to read configuration
-	v.SetConfigName("config")  // Base name of the config file
-	v.AddConfigPath(configDir) // Look in the config directory
-	v.SetConfigType("yaml")    // Config type is YAML
+	if opts.File != "" {
+		v.SetConfigFile(opts.File) // Explicit file (type taken from its extension)
+	} else {
+		v.SetConfigName("config")  // Base name of the config file
+		v.AddConfigPath(configDir) // Look in the config directory
+		v.SetConfigType("yaml")    // Config type is YAML
+	}
 
 	// Set default values in case config files are missing
 	v.SetDefault("taxCalculator.baseUrl", "http://localhost:5001/tax-calculator")
+	v.SetDefault("taxCalculator.traceConnections", false)
 	v.SetDefault("includeTaxYear", false)
+	v.SetDefault("includeJurisdiction", false)
 	v.SetDefault("port", "8080")
+	v.SetDefault("server.readTimeout", 10)              // Default: 10 seconds to read a request
+	v.SetDefault("server.readHeaderTimeout", 5)         // Default: 5 seconds to read headers
+	v.SetDefault("server.writeTimeout", 40)             // Default: 40 seconds (upstream timeout is 35s)
+	v.SetDefault("server.idleTimeout", 120)             // Default: 2 minutes keep-alive
+	v.SetDefault("server.maxHeaderBytes", 1<<20)        // Default: 1 MB
+	v.SetDefault("server.maxBodyBytes", 64<<10)         // Default: 64 KB calculation request bodies
+	v.SetDefault("server.shutdownDelay", 5)             // Default: 5 seconds not ready before draining
+	v.SetDefault("server.shutdownGracePeriod", 30)      // Default: 30 seconds to finish in-flight requests
+	v.SetDefault("tls.enabled", false)                  // Default: plain HTTP
+	v.SetDefault("tls.minVersion", "1.2")               // Default: TLS 1.2 or later
+	v.SetDefault("tls.cipherSuites", []string{})        // Default: Go's secure cipher suites
+	v.SetDefault("tls.clientAuth", "")                  // Default: require when clientCAFile is set
+	v.SetDefault("tls.reloadInterval", 60)              // Default: check certificate files every minute
+	v.SetDefault("admin.address", ":9080")              // Default: admin endpoints on port 9080
+	v.SetDefault("admin.tls", false)                    // Default: plain HTTP admin listener
+	v.SetDefault("admin.enablePprof", false)            // Default: profiling endpoints disabled
+	v.SetDefault("api.legacyDeprecation", "2026-11-01") // Default: /income-salary deprecated in favor of /v1
+	v.SetDefault("api.legacySunset", "2027-05-01")      // Default: /income-salary removed six months later
+	v.SetDefault("batch.maxItems", 10000)               // Default: 10,000 items per batch request
+	v.SetDefault("batch.maxBodyBytes", 10<<20)          // Default: 10 MB batch request bodies
+	v.SetDefault("validation.minSalary", 0)             // Default: any non-negative salary
+	v.SetDefault("validation.maxSalary", 1e9)           // Default: salaries up to 1,000,000,000
+	v.SetDefault("validation.minTaxYear", 2000)         // Default: tax years from 2000
+	v.SetDefault("validation.maxTaxYear", 0)            // Default: up to the current year
 	v.SetDefault("circuitBreakerEnabled", true)         // Default to enabled
 	v.SetDefault("circuitBreaker.requestThreshold", 5)  // Default: 5 requests minimum
 	v.SetDefault("circuitBreaker.failureRatio", 0.5)    // Default: 50% failures
@@ -41,31 +100,59 @@ func Load(env ...string) models.Config {
 	v.SetDefault("circuitBreaker.maxHalfOpenReqs", 100) // Default: 100 requests when half-open
 	v.SetDefault("logging.enabled", true)               // Default: logging enabled
 	v.SetDefault("logging.level", "INFO")               // Default: INFO level logging
+	v.SetDefault("logging.sampling.enabled", true)      // Default: sample repeated messages
+	v.SetDefault("logging.sampling.interval", 1)        // Default: 1 second windows
+	v.SetDefault("logging.sampling.first", 100)         // Default: first 100 per template per window
+	v.SetDefault("logging.sampling.thereafter", 100)    // Default: then 1 in 100
+	v.SetDefault("accessLog.enabled", true)             // Default: access log enabled
+	v.SetDefault("accessLog.format", "combined")        // Default: Combined Log Format
+	v.SetDefault("accessLog.sampleRate", 1.0)           // Default: log every request
+	v.SetDefault("accessLog.excludePaths", []string{"/metrics", "/healthz", "/readyz"})
+	v.SetDefault("accessLog.trustedProxies", []string{})
+	v.SetDefault("metrics.durationBuckets", []float64{}) // Default: Prometheus default buckets
+	v.SetDefault("metrics.sizeBuckets", []float64{})     // Default: 100B to ~1.6MB exponential buckets
+	v.SetDefault("tracing.enabled", false)               // Default: tracing disabled
+	v.SetDefault("tracing.exporter", "stdout")           // Default: print spans locally
+	v.SetDefault("tracing.endpoint", "localhost:4318")   // Default: local OTLP/HTTP collector
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.filePath", "traces.json")
+	v.SetDefault("tracing.sampleRatio", 1.0) // Default: sample every trace
+	v.SetDefault("tracing.serviceName", "taxapp")
+
+	// Admin credentials should come from the environment rather than config files
+	v.BindEnv("admin.auth.token", "TAXAPP_ADMIN_TOKEN")
+	v.BindEnv("admin.auth.username", "TAXAPP_ADMIN_USERNAME")
+	v.BindEnv("admin.auth.password", "TAXAPP_ADMIN_PASSWORD")
 
 	// Try to read the common config file
 	if err := v.ReadInConfig(); err != nil {
-		log.Printf("Warning: Could not read config file: %v", err)
+		if opts.File != "" {
+			return models.Config{}, fmt.Errorf("failed to read config file %s: %v", opts.File, err)
+		}
+		logf("Warning: Could not read config file: %v", err)
 	} else {
-		log.Printf("Loaded base configuration from %s", v.ConfigFileUsed())
+		logf("Loaded base configuration from %s", v.ConfigFileUsed())
 	}
 
 	// If we're not in dev environment, try to load env-specific config
-	if environment != "dev" {
+	if environment != "dev" && opts.File == "" {
 		v.SetConfigName("config." + environment)
 		if err := v.MergeInConfig(); err != nil {
-			log.Printf("Warning: Could not read environment config for '%s': %v", environment, err)
+			logf("Warning: Could not read environment config for '%s': %v", environment, err)
 		} else {
-			log.Printf("Loaded environment configuration from %s", v.ConfigFileUsed())
+			logf("Loaded environment configuration from %s", v.ConfigFileUsed())
 		}
 	}
 
 	// Create config with values from Viper
 	config := models.Config{
-		TaxCalcBaseURL:        v.GetString("taxCalculator.baseUrl"),
-		IncludeTaxYear:        v.GetBool("includeTaxYear"),
-		Port:                  v.GetString("port"),
-		Environment:           environment,
-		CircuitBreakerEnabled: v.GetBool("circuitBreakerEnabled"),
+		TaxCalcBaseURL:          v.GetString("taxCalculator.baseUrl"),
+		TaxCalcTraceConnections: v.GetBool("taxCalculator.traceConnections"),
+		IncludeTaxYear:          v.GetBool("includeTaxYear"),
+		IncludeJurisdiction:     v.GetBool("includeJurisdiction"),
+		Port:                    v.GetString("port"),
+		Environment:             environment,
+		CircuitBreakerEnabled:   v.GetBool("circuitBreakerEnabled"),
 		CircuitBreaker: models.CircuitBreakerConfig{
 			RequestThreshold: v.GetInt("circuitBreaker.requestThreshold"),
 			FailureRatio:     v.GetFloat64("circuitBreaker.failureRatio"),
@@ -75,24 +162,134 @@ func Load(env ...string) models.Config {
 		Logging: models.LoggingConfig{
 			Enabled: v.GetBool("logging.enabled"),
 			Level:   v.GetString("logging.level"),
+			Sampling: models.LogSamplingConfig{
+				Enabled:    v.GetBool("logging.sampling.enabled"),
+				Interval:   v.GetInt("logging.sampling.interval"),
+				First:      v.GetInt("logging.sampling.first"),
+				Thereafter: v.GetInt("logging.sampling.thereafter"),
+			},
+		},
+		AccessLog: models.AccessLogConfig{
+			Enabled:        v.GetBool("accessLog.enabled"),
+			Format:         v.GetString("accessLog.format"),
+			SampleRate:     v.GetFloat64("accessLog.sampleRate"),
+			ExcludePaths:   v.GetStringSlice("accessLog.excludePaths"),
+			TrustedProxies: v.GetStringSlice("accessLog.trustedProxies"),
+		},
+		Metrics: models.MetricsConfig{
+			DurationBuckets: getFloat64Slice(v, "metrics.durationBuckets"),
+			SizeBuckets:     getFloat64Slice(v, "metrics.sizeBuckets"),
+		},
+		Tracing: models.TracingConfig{
+			Enabled:     v.GetBool("tracing.enabled"),
+			Exporter:    v.GetString("tracing.exporter"),
+			Endpoint:    v.GetString("tracing.endpoint"),
+			Insecure:    v.GetBool("tracing.insecure"),
+			FilePath:    v.GetString("tracing.filePath"),
+			SampleRatio: v.GetFloat64("tracing.sampleRatio"),
+			ServiceName: v.GetString("tracing.serviceName"),
+		},
+		Server: models.ServerConfig{
+			ReadTimeout:         v.GetInt("server.readTimeout"),
+			ReadHeaderTimeout:   v.GetInt("server.readHeaderTimeout"),
+			WriteTimeout:        v.GetInt("server.writeTimeout"),
+			IdleTimeout:         v.GetInt("server.idleTimeout"),
+			MaxHeaderBytes:      v.GetInt("server.maxHeaderBytes"),
+			MaxBodyBytes:        v.GetInt("server.maxBodyBytes"),
+			ShutdownDelay:       v.GetInt("server.shutdownDelay"),
+			ShutdownGracePeriod: v.GetInt("server.shutdownGracePeriod"),
+		},
+		TLS: models.TLSConfig{
+			Enabled:        v.GetBool("tls.enabled"),
+			CertFile:       v.GetString("tls.certFile"),
+			KeyFile:        v.GetString("tls.keyFile"),
+			MinVersion:     v.GetString("tls.minVersion"),
+			CipherSuites:   v.GetStringSlice("tls.cipherSuites"),
+			ClientCAFile:   v.GetString("tls.clientCAFile"),
+			ClientAuth:     v.GetString("tls.clientAuth"),
+			ReloadInterval: v.GetInt("tls.reloadInterval"),
+		},
+		Admin: models.AdminConfig{
+			Address:     v.GetString("admin.address"),
+			TLS:         v.GetBool("admin.tls"),
+			EnablePprof: v.GetBool("admin.enablePprof"),
+			Auth: models.AdminAuthConfig{
+				Token:    v.GetString("admin.auth.token"),
+				Username: v.GetString("admin.auth.username"),
+				Password: v.GetString("admin.auth.password"),
+			},
+		},
+		API: models.APIConfig{
+			LegacyDeprecation: v.GetString("api.legacyDeprecation"),
+			LegacySunset:      v.GetString("api.legacySunset"),
+		},
+		Batch: models.BatchConfig{
+			MaxItems:     v.GetInt("batch.maxItems"),
+			MaxBodyBytes: v.GetInt64("batch.maxBodyBytes"),
+		},
+		Validation: models.ValidationConfig{
+			MinSalary:  v.GetFloat64("validation.minSalary"),
+			MaxSalary:  v.GetFloat64("validation.maxSalary"),
+			MinTaxYear: v.GetInt("validation.minTaxYear"),
+			MaxTaxYear: v.GetInt("validation.maxTaxYear"),
 		},
 	}
 
 	// Configure the logger based on the settings
+	level := logger.LevelFromString(config.Logging.Level)
+	if opts.Quiet && level > logger.LevelError {
+		level = logger.LevelError
+	}
 	logger.Configure(logger.Config{
 		Enabled: config.Logging.Enabled,
-		Level:   logger.LevelFromString(config.Logging.Level),
+		Level:   level,
 		Output:  nil, // Use default (stdout)
+		Sampling: logger.SamplingConfig{
+			Enabled:    config.Logging.Sampling.Enabled,
+			Interval:   time.Duration(config.Logging.Sampling.Interval) * time.Second,
+			First:      config.Logging.Sampling.First,
+			Thereafter: config.Logging.Sampling.Thereafter,
+		},
 	})
 
 	// Use our new logger for remaining configuration logs
-	logger.Info("Configuration loaded for environment '%s': TaxCalcBaseURL=%s, IncludeTaxYear=%v, Port=%s, CircuitBreakerEnabled=%v",
-		environment, config.TaxCalcBaseURL, config.IncludeTaxYear, config.Port, config.CircuitBreakerEnabled)
+	logger.Info("Configuration loaded for environment '%s': TaxCalcBaseURL=%s, IncludeTaxYear=%v, IncludeJurisdiction=%v, Port=%s, CircuitBreakerEnabled=%v",
+		environment, config.TaxCalcBaseURL, config.IncludeTaxYear, config.IncludeJurisdiction, config.Port, config.CircuitBreakerEnabled)
 	logger.Info("Circuit Breaker Config: RequestThreshold=%d, FailureRatio=%.2f, Timeout=%ds, MaxHalfOpenReqs=%d",
 		config.CircuitBreaker.RequestThreshold, config.CircuitBreaker.FailureRatio,
 		config.CircuitBreaker.Timeout, config.CircuitBreaker.MaxHalfOpenReqs)
-	logger.Info("Logging Config: Enabled=%v, Level=%s",
-		config.Logging.Enabled, config.Logging.Level)
+	logger.Info("Server Config: ReadTimeout=%ds, ReadHeaderTimeout=%ds, WriteTimeout=%ds, IdleTimeout=%ds, MaxHeaderBytes=%d, MaxBodyBytes=%d, ShutdownDelay=%ds, ShutdownGracePeriod=%ds",
+		config.Server.ReadTimeout, config.Server.ReadHeaderTimeout, config.Server.WriteTimeout, config.Server.IdleTimeout,
+		config.Server.MaxHeaderBytes, config.Server.MaxBodyBytes, config.Server.ShutdownDelay, config.Server.ShutdownGracePeriod)
+	logger.Info("TLS Config: Enabled=%v, CertFile=%s, MinVersion=%s, ClientCAFile=%s, ClientAuth=%s",
+		config.TLS.Enabled, config.TLS.CertFile, config.TLS.MinVersion, config.TLS.ClientCAFile, config.TLS.ClientAuth)
+	logger.Info("Admin Config: Address=%s, TLS=%v, EnablePprof=%v, TokenAuth=%v, BasicAuth=%v",
+		config.Admin.Address, config.Admin.TLS, config.Admin.EnablePprof, config.Admin.Auth.Token != "", config.Admin.Auth.Username != "")
+	logger.Info("API Config: LegacyDeprecation=%s, LegacySunset=%s", config.API.LegacyDeprecation, config.API.LegacySunset)
+	logger.Info("Batch Config: MaxItems=%d, MaxBodyBytes=%d", config.Batch.MaxItems, config.Batch.MaxBodyBytes)
+	logger.Info("Validation Config: MinSalary=%.2f, MaxSalary=%.2f, MinTaxYear=%d, MaxTaxYear=%d",
+		config.Validation.MinSalary, config.Validation.MaxSalary, config.Validation.MinTaxYear, config.Validation.MaxTaxYear)
+	logger.Info("Logging Config: Enabled=%v, Level=%s, Sampling=%v (first %d then 1 in %d per %ds)",
+		config.Logging.Enabled, config.Logging.Level, config.Logging.Sampling.Enabled,
+		config.Logging.Sampling.First, config.Logging.Sampling.Thereafter, config.Logging.Sampling.Interval)
+	logger.Info("Access Log Config: Enabled=%v, Format=%s, SampleRate=%.2f, ExcludePaths=%v",
+		config.AccessLog.Enabled, config.AccessLog.Format, config.AccessLog.SampleRate, config.AccessLog.ExcludePaths)
+	logger.Info("Tracing Config: Enabled=%v, Exporter=%s, Endpoint=%s, SampleRatio=%.2f",
+		config.Tracing.Enabled, config.Tracing.Exporter, config.Tracing.Endpoint, config.Tracing.SampleRatio)
 
-	return config
+	return config, nil
+}
+
+// getFloat64Slice reads a list of numbers from the configuration, skipping invalid entries
+func getFloat64Slice(v *viper.Viper, key string) []float64 {
+	var values []float64
+	for _, item := range cast.ToSlice(v.Get(key)) {
+		value, err := cast.ToFloat64E(item)
+		if err != nil {
+			logger.Warn("Ignoring invalid value %v in %s: %v", item, key, err)
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
 }
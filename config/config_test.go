@@ -1,6 +1,9 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -59,3 +62,54 @@ func TestLoadConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadWithOptions(t *testing.T) {
+	t.Run("Explicit config file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "taxapp.yaml")
+		os.WriteFile(path, []byte("port: \"9999\"\ntaxCalculator:\n  baseUrl: http://calculator.internal\n"), 0600)
+
+		config, err := LoadWithOptions(Options{Environment: "prod", File: path, Quiet: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.Port != "9999" || config.TaxCalcBaseURL != "http://calculator.internal" {
+			t.Errorf("expected values from the explicit file but got port %s and base URL %s", config.Port, config.TaxCalcBaseURL)
+		}
+		if config.IncludeTaxYear {
+			t.Errorf("expected config.prod.yaml not to be merged into an explicit file")
+		}
+		if config.Environment != "prod" {
+			t.Errorf("expected environment prod but got %s", config.Environment)
+		}
+	})
+
+	t.Run("Missing explicit config file", func(t *testing.T) {
+		if _, err := LoadWithOptions(Options{File: "does-not-exist.yaml", Quiet: true}); err == nil {
+			t.Errorf("expected an error for a missing config file")
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(Load()); err != nil {
+		t.Errorf("expected dev configuration to be valid but got: %v", err)
+	}
+
+	config := Load()
+	config.TaxCalcBaseURL = "localhost:5001"
+	config.CircuitBreaker.FailureRatio = 1.5
+	config.AccessLog.Format = "xml"
+	config.Admin.Address = ":" + config.Port
+	config.Validation.MinTaxYear = 2020
+	config.Validation.MaxTaxYear = 2019
+
+	err := Validate(config)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, key := range []string{"taxCalculator.baseUrl", "circuitBreaker.failureRatio", "accessLog.format", "admin.address", "validation.maxTaxYear"} {
+		if !strings.Contains(err.Error(), key+":") {
+			t.Errorf("expected an error for %s but got: %v", key, err)
+		}
+	}
+}
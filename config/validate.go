@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pulsegrade/test1/lifecycle"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/tlsconfig"
+	"pulsegrade/test1/tracing"
+)
+
+// Validate checks the configuration for values the application cannot run with,
+// returning every problem found (joined) rather than stopping at the first one
+func Validate(config models.Config) error {
+	var errs []error
+	check := func(ok bool, key, format string, v ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, v...)))
+		}
+	}
+
+	baseURL, err := url.Parse(config.TaxCalcBaseURL)
+	check(err == nil && (baseURL.Scheme == "http" || baseURL.Scheme == "https") && baseURL.Host != "",
+		"taxCalculator.baseUrl", "must be an absolute http(s) URL, got %q", config.TaxCalcBaseURL)
+
+	port, err := strconv.Atoi(config.Port)
+	check(err == nil && port > 0 && port <= 65535, "port", "must be a number between 1 and 65535, got %q", config.Port)
+
+	check(config.Server.ReadTimeout >= 0, "server.readTimeout", "must not be negative")
+	check(config.Server.ReadHeaderTimeout >= 0, "server.readHeaderTimeout", "must not be negative")
+	check(config.Server.WriteTimeout >= 0, "server.writeTimeout", "must not be negative")
+	check(config.Server.IdleTimeout >= 0, "server.idleTimeout", "must not be negative")
+	check(config.Server.MaxHeaderBytes >= 0, "server.maxHeaderBytes", "must not be negative")
+	check(config.Server.MaxBodyBytes >= 0, "server.maxBodyBytes", "must not be negative")
+	check(config.Server.ShutdownDelay >= 0, "server.shutdownDelay", "must not be negative")
+	check(config.Server.ShutdownGracePeriod >= 0, "server.shutdownGracePeriod", "must not be negative")
+
+	if config.TLS.Enabled || (config.Admin.TLS && !lifecycle.IsUnixSocket(config.Admin.Address)) {
+		// Loading the TLS configuration checks versions, cipher suites and the certificate files
+		if _, _, err := tlsconfig.New(config.TLS); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %v", err))
+		}
+	}
+
+	check(config.Admin.Address != "", "admin.address", "must be set")
+	if !lifecycle.IsUnixSocket(config.Admin.Address) {
+		_, adminPort, err := net.SplitHostPort(config.Admin.Address)
+		check(err == nil, "admin.address", "must be host:port or unix:/path, got %q", config.Admin.Address)
+		check(err != nil || adminPort != config.Port, "admin.address", "must not use the public port %s", config.Port)
+	}
+	check(config.Admin.Auth.Username == "" || config.Admin.Auth.Password != "", "admin.auth.password", "must be set when a username is configured")
+
+	check(isDate(config.API.LegacyDeprecation), "api.legacyDeprecation", "must be a date (YYYY-MM-DD), got %q", config.API.LegacyDeprecation)
+	check(isDate(config.API.LegacySunset), "api.legacySunset", "must be a date (YYYY-MM-DD), got %q", config.API.LegacySunset)
+
+	check(config.Batch.MaxItems > 0, "batch.maxItems", "must be positive")
+	check(config.Batch.MaxBodyBytes > 0, "batch.maxBodyBytes", "must be positive")
+
+	check(config.Validation.MinSalary >= 0, "validation.minSalary", "must not be negative")
+	check(config.Validation.MaxSalary == 0 || config.Validation.MaxSalary >= config.Validation.MinSalary,
+		"validation.maxSalary", "must be 0 (no limit) or at least validation.minSalary, got %v", config.Validation.MaxSalary)
+	check(config.Validation.MinTaxYear >= 0, "validation.minTaxYear", "must not be negative")
+	check(config.Validation.MaxTaxYear == 0 || config.Validation.MaxTaxYear >= config.Validation.MinTaxYear,
+		"validation.maxTaxYear", "must be 0 (the current year) or at least validation.minTaxYear, got %d", config.Validation.MaxTaxYear)
+
+	if config.CircuitBreakerEnabled {
+		check(config.CircuitBreaker.RequestThreshold > 0, "circuitBreaker.requestThreshold", "must be positive")
+		check(config.CircuitBreaker.FailureRatio > 0 && config.CircuitBreaker.FailureRatio <= 1,
+			"circuitBreaker.failureRatio", "must be in (0, 1], got %v", config.CircuitBreaker.FailureRatio)
+		check(config.CircuitBreaker.Timeout > 0, "circuitBreaker.timeout", "must be positive")
+		check(config.CircuitBreaker.MaxHalfOpenReqs > 0, "circuitBreaker.maxHalfOpenReqs", "must be positive")
+	}
+
+	check(oneOf(config.Logging.Level, "NONE", "ERROR", "WARN", "INFO", "DEBUG"),
+		"logging.level", "must be one of NONE, ERROR, WARN, INFO, DEBUG, got %q", config.Logging.Level)
+	if config.Logging.Sampling.Enabled {
+		check(config.Logging.Sampling.Interval > 0, "logging.sampling.interval", "must be positive")
+		check(config.Logging.Sampling.First >= 0, "logging.sampling.first", "must not be negative")
+		check(config.Logging.Sampling.Thereafter >= 0, "logging.sampling.thereafter", "must not be negative")
+	}
+
+	check(oneOf(strings.ToLower(config.AccessLog.Format), "common", "combined", "json"),
+		"accessLog.format", "must be one of common, combined, json, got %q", config.AccessLog.Format)
+	check(config.AccessLog.SampleRate >= 0 && config.AccessLog.SampleRate <= 1,
+		"accessLog.sampleRate", "must be between 0 and 1, got %v", config.AccessLog.SampleRate)
+	for _, proxy := range config.AccessLog.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "accessLog.trustedProxies", "%q is not an IP or CIDR", proxy)
+	}
+
+	check(sort.Float64sAreSorted(config.Metrics.DurationBuckets), "metrics.durationBuckets", "must be in increasing order")
+	check(sort.Float64sAreSorted(config.Metrics.SizeBuckets), "metrics.sizeBuckets", "must be in increasing order")
+
+	if config.Tracing.Enabled {
+		exporter := strings.ToLower(config.Tracing.Exporter)
+		check(oneOf(exporter, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterFile),
+			"tracing.exporter", "must be one of otlp, stdout, file, got %q", config.Tracing.Exporter)
+		check(exporter != tracing.ExporterOTLP || config.Tracing.Endpoint != "", "tracing.endpoint", "must be set for the otlp exporter")
+		check(exporter != tracing.ExporterFile || config.Tracing.FilePath != "", "tracing.filePath", "must be set for the file exporter")
+		check(config.Tracing.SampleRatio >= 0 && config.Tracing.SampleRatio <= 1,
+			"tracing.sampleRatio", "must be between 0 and 1, got %v", config.Tracing.SampleRatio)
+	}
+
+	return errors.Join(errs...)
+}
+
+// oneOf reports whether value is one of the allowed values
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// isDate reports whether value is empty or a YYYY-MM-DD date
+func isDate(value string) bool {
+	_, err := time.Parse(time.DateOnly, value)
+	return value == "" || err == nil
+}
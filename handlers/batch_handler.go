@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"pulsegrade/test1/batch"
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Batch request and response media types
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+// batchFlushInterval is the number of results written between flushes of a streamed batch response
+const batchFlushInterval = 100
+
+// batchResponseSummary is the last line of an NDJSON batch response
+type batchResponseSummary struct {
+	Summary batch.Summary `json:"summary"`
+}
+
+// HandleBatch processes POST /income-salary/batch. The body is a JSON array, or
+// NDJSON (one item per line), of {"id", "salary", "year"} items. Each distinct
+// year's brackets are fetched once, then a result per item is streamed back in
+// the same format as the request: {"results":[...],"summary":{...}} for JSON,
+// or one result per line followed by a {"summary":{...}} line for NDJSON. Items
+// that fail carry an error of their own; the request only fails as a whole when
+// the body is unreadable or exceeds the configured limits.
+func (h *IncomeSalaryHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		WriteProblem(w, r, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Use POST to submit a batch")
+		return
+	}
+
+	format, responseType, err := batchFormat(r.Header.Get("Content-Type"))
+	if err != nil {
+		w.Header().Set("Accept-Post", contentTypeJSON+", "+contentTypeNDJSON)
+		h.writeError(w, r, err)
+		return
+	}
+
+	// Read the whole batch before responding so limits and malformed bodies are
+	// reported with a status code rather than midway through a streamed response
+	body := http.MaxBytesReader(w, r.Body, h.config.Batch.MaxBodyBytes)
+	records, err := batch.ReadRecords(body, format, h.config.Batch.MaxItems)
+	if err != nil {
+		if errors.Is(err, batch.ErrTooManyRecords) {
+			WriteProblem(w, r, http.StatusRequestEntityTooLarge, models.CodePayloadTooLarge,
+				fmt.Sprintf("The batch exceeds the maximum of %d items", h.config.Batch.MaxItems))
+			return
+		}
+		h.writeError(w, r, bodyError(err))
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "ProcessBatch")
+	span.SetAttributes(attribute.Int("batch.items", len(records)))
+	defer span.End()
+
+	processor := batch.NewProcessor(h.taxCalculator, batch.Options{
+		BaseURL:        h.config.TaxCalcBaseURL,
+		IncludeTaxYear: h.config.IncludeTaxYear,
+		Rules:          h.rules,
+	})
+
+	w.Header().Set("Content-Type", responseType)
+	flusher, _ := w.(http.Flusher)
+	stream := newBatchStream(w, responseType)
+
+	written := 0
+	summary, err := processor.Stream(ctx, records, func(result batch.Result) error {
+		if result.Error == "" {
+			h.recordCalculation("batch", result.Salary, result.Year, result.EffectiveRate)
+		}
+		if err := stream.write(result); err != nil {
+			return err
+		}
+		if written++; written%batchFlushInterval == 0 && flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// The status was already sent; the client sees a truncated response
+		logger.Warn("Batch response aborted after %d of %d items: %v", written, len(records), err)
+		return
+	}
+
+	span.SetAttributes(attribute.Int("batch.failed", summary.Failed))
+	stream.finish(summary)
+}
+
+// batchFormat returns the input format and response media type for a request Content-Type
+func batchFormat(contentType string) (batch.Format, string, error) {
+	if contentType == "" {
+		return batch.FormatJSON, contentTypeJSON, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", "", unsupportedMediaType(fmt.Sprintf("Invalid Content-Type %q", contentType))
+	}
+	switch mediaType {
+	case contentTypeJSON:
+		return batch.FormatJSON, contentTypeJSON, nil
+	case contentTypeNDJSON, "application/ndjson", "application/jsonl":
+		return batch.FormatJSONL, contentTypeNDJSON, nil
+	default:
+		return "", "", unsupportedMediaType(fmt.Sprintf("Unsupported Content-Type %q; use %s or %s", mediaType, contentTypeJSON, contentTypeNDJSON))
+	}
+}
+
+// batchStream writes batch results incrementally in the response format
+type batchStream struct {
+	w       http.ResponseWriter
+	ndjson  bool
+	written int
+}
+
+// newBatchStream creates a stream for the given response media type
+func newBatchStream(w http.ResponseWriter, responseType string) *batchStream {
+	return &batchStream{w: w, ndjson: responseType == contentTypeNDJSON}
+}
+
+// write writes one result
+func (s *batchStream) write(result batch.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	// JSON items are separated by commas inside the results array; NDJSON items end with a newline
+	prefix, suffix := ",\n", ""
+	switch {
+	case s.ndjson:
+		prefix, suffix = "", "\n"
+	case s.written == 0:
+		prefix = "{\"results\":[\n"
+	}
+	s.written++
+	_, err = fmt.Fprintf(s.w, "%s%s%s", prefix, data, suffix)
+	return err
+}
+
+// finish writes the summary, closing the results array for JSON
+func (s *batchStream) finish(summary batch.Summary) {
+	if s.ndjson {
+		json.NewEncoder(s.w).Encode(batchResponseSummary{Summary: summary})
+		return
+	}
+
+	data, _ := json.Marshal(summary)
+	switch s.written {
+	case 0:
+		fmt.Fprintf(s.w, "{\"results\":[],\"summary\":%s}\n", data)
+	default:
+		fmt.Fprintf(s.w, "\n],\"summary\":%s}\n", data)
+	}
+}
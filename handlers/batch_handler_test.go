@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"pulsegrade/test1/batch"
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHandleBatch(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		if r.URL.Path == "/tax-year/2019" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/tax-year/2018" {
+			fmt.Fprint(w, `{"jurisdictions":[{"code":"US","tax_brackets":[{"min":0,"rate":0.1}]}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.15},{"min":50000,"rate":0.25}]}`)
+	}))
+	defer mockServer.Close()
+
+	cfg := models.Config{
+		TaxCalcBaseURL: mockServer.URL,
+		IncludeTaxYear: true,
+		Batch:          models.BatchConfig{MaxItems: 3, MaxBodyBytes: 1024},
+	}
+	registry := metrics.NewRegistry(metrics.Options{})
+	handler := NewIncomeSalaryHandler(cfg, registry)
+
+	t.Run("JSON array", func(t *testing.T) {
+		upstreamCalls.Store(0)
+		body := `[{"id":"e1","salary":75000,"year":2022},{"id":"e2","salary":"abc"},{"id":"e3","salary":40000,"year":2022}]`
+		req := httptest.NewRequest(http.MethodPost, "/income-salary/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleBatch(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+		}
+
+		var response struct {
+			Results []batch.Result `json:"results"`
+			Summary batch.Summary  `json:"summary"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+		}
+		if len(response.Results) != 3 || response.Results[0].Tax != 13750 || response.Results[2].ID != "e3" {
+			t.Errorf("unexpected results %+v", response.Results)
+		}
+		if response.Results[1].Error == "" {
+			t.Errorf("expected a per-item error for an invalid salary")
+		}
+		if response.Summary.Succeeded != 2 || response.Summary.Failed != 1 {
+			t.Errorf("unexpected summary %+v", response.Summary)
+		}
+		if calls := upstreamCalls.Load(); calls != 1 {
+			t.Errorf("expected the 2022 brackets to be fetched once but got %d calls", calls)
+		}
+		if count := registry.CounterValue("taxapp_tax_calculations_by_year_total", prometheus.Labels{"tax_year": "2022", "channel": "batch"}); count != 2 {
+			t.Errorf("expected 2 batch calculations recorded but got %v", count)
+		}
+	})
+
+	t.Run("NDJSON stream", func(t *testing.T) {
+		body := `{"id":"e1","salary":75000}` + "\n" + `{"id":"e2","salary":60000,"year":2019}` + "\n"
+		req := httptest.NewRequest(http.MethodPost, "/income-salary/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		handler.HandleBatch(w, req)
+
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+			t.Errorf("expected an NDJSON response but got %q", contentType)
+		}
+
+		var lines []string
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if len(lines) != 3 {
+			t.Fatalf("expected 2 results and a summary line but got:\n%s", strings.Join(lines, "\n"))
+		}
+		if !strings.Contains(lines[1], "failed to fetch tax brackets") || !strings.Contains(lines[1], `"code":"tax_year_not_found"`) {
+			t.Errorf("expected the 2019 item to report the upstream failure but got %s", lines[1])
+		}
+		if !strings.HasPrefix(lines[2], `{"summary":`) {
+			t.Errorf("expected a trailing summary line but got %s", lines[2])
+		}
+	})
+
+	t.Run("Per-jurisdiction brackets only", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/income-salary/batch", strings.NewReader(`[{"id":"e1","salary":60000,"year":2018}]`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleBatch(w, req)
+
+		var response struct {
+			Results []batch.Result `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+		}
+		if len(response.Results) != 1 || response.Results[0].Code != models.CodeUpstreamError {
+			t.Errorf("expected the item to fail with %q but got %+v", models.CodeUpstreamError, response.Results)
+		}
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{name: "Wrong method", method: http.MethodGet, contentType: "application/json", body: "", status: http.StatusMethodNotAllowed, code: models.CodeMethodNotAllowed},
+		{name: "Unsupported media type", method: http.MethodPost, contentType: "text/plain", body: "e1,1", status: http.StatusUnsupportedMediaType, code: models.CodeUnsupportedMediaType},
+		{name: "Malformed JSON", method: http.MethodPost, contentType: "application/json", body: `[{"id":`, status: http.StatusBadRequest, code: models.CodeInvalidBody},
+		{name: "Too many items", method: http.MethodPost, contentType: "application/json", body: `[{"salary":1},{"salary":2},{"salary":3},{"salary":4}]`, status: http.StatusRequestEntityTooLarge, code: models.CodePayloadTooLarge},
+		{name: "Body too large", method: http.MethodPost, contentType: "application/json", body: `[{"id":"` + strings.Repeat("x", 2048) + `"}]`, status: http.StatusRequestEntityTooLarge, code: models.CodePayloadTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/income-salary/batch", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			handler.HandleBatch(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("expected status %d but got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if problem := decodeProblem(t, w); problem.Code != tc.code {
+				t.Errorf("expected code %q but got %q", tc.code, problem.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+	"pulsegrade/test1/validation"
+)
+
+// Cache lifetimes of bracket responses: past years' brackets are final, while
+// the current (or a future) year may still be revised upstream
+const (
+	bracketsMaxAgePastYear    = 24 * time.Hour
+	bracketsMaxAgeCurrentYear = time.Hour
+)
+
+// HandleBrackets processes GET /v1/tax-brackets/{year}, returning the validated
+// brackets of a tax year fetched through the tax calculator (and its circuit
+// breaker). With ?cumulative=true each bracket also carries the total tax owed
+// at its upper bound. Responses carry an ETag derived from the body, and
+// If-None-Match requests for unchanged brackets get 304.
+func (h *IncomeSalaryHandler) HandleBrackets(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(r.PathValue("year"))
+	if err != nil || year <= 0 {
+		h.writeError(w, r, newValidationError(models.FieldError{Field: "year", Code: validation.CodeInvalid,
+			Message: fmt.Sprintf("year must be a positive integer, got %q", r.PathValue("year"))}))
+		return
+	}
+	if field := h.rules.TaxYear(year); field != nil {
+		h.writeError(w, r, newValidationError(*field))
+		return
+	}
+
+	cumulative := false
+	if value := r.URL.Query().Get("cumulative"); value != "" {
+		if cumulative, err = strconv.ParseBool(value); err != nil {
+			h.writeError(w, r, newValidationError(models.FieldError{Field: "cumulative", Code: validation.CodeInvalid,
+				Message: fmt.Sprintf("cumulative must be true or false, got %q", value)}))
+			return
+		}
+	}
+
+	// Brackets are validated by the tax calculator; an invalid schedule is an upstream error
+	taxResponse, err := h.taxCalculator.FetchTaxData(r.Context(), services.TaxDataURL(h.config.TaxCalcBaseURL, year), year)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	body, err := json.Marshal(models.BracketsResponse{
+		Year:     year,
+		Brackets: h.scheduleBrackets(taxResponse.TaxBrackets, cumulative),
+	})
+	if err != nil {
+		logger.Error("Failed to encode the brackets of %d: %v", year, err)
+		WriteProblem(w, r, http.StatusInternalServerError, models.CodeInternal, "Failed to encode the response")
+		return
+	}
+	body = append(body, '\n')
+
+	maxAge := bracketsMaxAgeCurrentYear
+	if year < time.Now().Year() {
+		maxAge = bracketsMaxAgePastYear
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// scheduleBrackets converts brackets for the response, adding the cumulative
+// tax at each bracket's upper bound (rounded to cents) when requested
+func (h *IncomeSalaryHandler) scheduleBrackets(brackets []models.TaxBracket, cumulative bool) []models.ScheduleBracket {
+	schedule := make([]models.ScheduleBracket, len(brackets))
+	for i, bracket := range brackets {
+		schedule[i] = models.ScheduleBracket{Min: bracket.Min, Max: bracket.Max, Rate: bracket.Rate}
+		if cumulative && bracket.Max != 0 {
+			tax, _ := h.taxCalculator.CalculateTax(bracket.Max, brackets)
+			tax = math.Round(tax*100) / 100
+			schedule[i].CumulativeTax = &tax
+		}
+	}
+	return schedule
+}
+
+// matchesETag reports whether an If-None-Match header matches etag. Weak
+// validators match too, as RFC 9110 requires for If-None-Match.
+func matchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+)
+
+func TestHandleBrackets(t *testing.T) {
+	brackets := `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":50000,"max":100000,"rate":0.2},{"min":100000,"rate":0.3}]}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tax-year/2020":
+			// Overlapping brackets
+			fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":40000,"rate":0.2}]}`)
+		case "/tax-year/2021":
+			fmt.Fprint(w, `{"jurisdictions":[{"code":"US","tax_brackets":[{"min":0,"rate":0.1}]}]}`)
+		default:
+			fmt.Fprint(w, brackets)
+		}
+	}))
+	defer upstream.Close()
+
+	handler := NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: upstream.URL}, metrics.NewNoop())
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.SetPathValue("year", req.URL.Path[len("/v1/tax-brackets/"):])
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.HandleBrackets(w, req)
+		return w
+	}
+
+	t.Run("Brackets", func(t *testing.T) {
+		w := get("/v1/tax-brackets/2022", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+		}
+		var response models.BracketsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Year != 2022 || len(response.Brackets) != 3 || response.Brackets[0].CumulativeTax != nil {
+			t.Errorf("unexpected response %+v", response)
+		}
+		if w.Header().Get("ETag") == "" || w.Header().Get("Cache-Control") != "public, max-age=86400" {
+			t.Errorf("expected an ETag and a day-long Cache-Control for a past year but got %q and %q",
+				w.Header().Get("ETag"), w.Header().Get("Cache-Control"))
+		}
+	})
+
+	t.Run("Cumulative tax", func(t *testing.T) {
+		w := get("/v1/tax-brackets/2022?cumulative=true", "")
+		var response models.BracketsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		expected := []float64{5000, 15000}
+		for i, want := range expected {
+			if got := response.Brackets[i].CumulativeTax; got == nil || *got != want {
+				t.Errorf("expected cumulative tax %v for bracket %d but got %v", want, i+1, got)
+			}
+		}
+		if response.Brackets[2].CumulativeTax != nil {
+			t.Errorf("expected no cumulative tax for the top bracket")
+		}
+		if w.Header().Get("ETag") == get("/v1/tax-brackets/2022", "").Header().Get("ETag") {
+			t.Errorf("expected the ETag to depend on the response body")
+		}
+	})
+
+	t.Run("Not modified", func(t *testing.T) {
+		etag := get("/v1/tax-brackets/2022", "").Header().Get("ETag")
+		w := get("/v1/tax-brackets/2022", "W/"+etag)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("expected 304 without a body but got %d: %s", w.Code, w.Body.String())
+		}
+		if w := get("/v1/tax-brackets/2022", `"stale"`); w.Code != http.StatusOK {
+			t.Errorf("expected 200 for a stale ETag but got %d", w.Code)
+		}
+	})
+
+	t.Run("Invalid brackets", func(t *testing.T) {
+		w := get("/v1/tax-brackets/2020", "")
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected status 502 but got %d", w.Code)
+		}
+		if problem := decodeProblem(t, w); problem.Code != models.CodeUpstreamError {
+			t.Errorf("expected code %q but got %q", models.CodeUpstreamError, problem.Code)
+		}
+	})
+
+	t.Run("Per-jurisdiction brackets only", func(t *testing.T) {
+		w := get("/v1/tax-brackets/2021", "")
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected status 502 but got %d", w.Code)
+		}
+		if problem := decodeProblem(t, w); problem.Code != models.CodeUpstreamError {
+			t.Errorf("expected code %q but got %q", models.CodeUpstreamError, problem.Code)
+		}
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, path := range []string{"/v1/tax-brackets/abc", "/v1/tax-brackets/99999", "/v1/tax-brackets/2022?cumulative=maybe"} {
+			if w := get(path, ""); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %s but got %d", path, w.Code)
+			}
+		}
+	})
+}
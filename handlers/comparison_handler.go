@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+	"pulsegrade/test1/tracing"
+	"pulsegrade/test1/validation"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxComparisonYears bounds the upstream calls a single comparison can make
+const maxComparisonYears = 20
+
+// HandleComparison processes GET /v1/tax-comparison, comparing the tax on a
+// salary across tax years given as a list (?years=2021,2022) or a range
+// (?from=2019&to=2022). Each year's brackets are fetched in parallel through
+// the tax calculator; every year reports its tax, effective and marginal rate
+// and the change from the previous year that succeeded. Years whose brackets
+// could not be fetched carry their own error; the request only fails when
+// every year failed.
+func (h *IncomeSalaryHandler) HandleComparison(w http.ResponseWriter, r *http.Request) {
+	salary, years, err := h.parseComparison(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "CompareTaxYears")
+	span.SetAttributes(attribute.Int("comparison.years", len(years)))
+	defer span.End()
+
+	response := models.ComparisonResponse{Salary: salary, Years: make([]models.YearTax, 0, len(years))}
+	previous := -1 // Index of the last year that succeeded
+	var firstErr error
+	for _, result := range h.taxCalculator.FetchYears(ctx, h.config.TaxCalcBaseURL, years) {
+		if result.Err != nil {
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			logger.Error("Failed to fetch tax brackets for year %d (request_id=%s): %v",
+				result.Year, logger.RequestIDFromContext(r.Context()), result.Err)
+			response.Years = append(response.Years, models.YearTax{Year: result.Year,
+				Error: "failed to fetch tax brackets", Code: services.ErrorCode(result.Err)})
+			continue
+		}
+
+		yearTax := models.YearTax{Year: result.Year}
+		yearTax.Tax, yearTax.EffectiveRate = h.taxCalculator.CalculateTax(salary, result.Brackets)
+		yearTax.Tax = math.Round(yearTax.Tax*100) / 100 // Rounded to cents like single calculations
+		yearTax.MarginalRate = h.taxCalculator.MarginalRate(salary, result.Brackets)
+		if previous >= 0 {
+			from := response.Years[previous]
+			yearTax.Change = &models.YearChange{
+				FromYear:      from.Year,
+				Tax:           math.Round((yearTax.Tax-from.Tax)*100) / 100,
+				EffectiveRate: roundRate(yearTax.EffectiveRate - from.EffectiveRate),
+				MarginalRate:  roundRate(yearTax.MarginalRate - from.MarginalRate),
+			}
+		}
+		previous = len(response.Years)
+		response.Years = append(response.Years, yearTax)
+	}
+
+	if previous < 0 {
+		// Nothing to compare: report the failure like a single calculation would
+		h.writeError(w, r, firstErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseComparison reads the salary and the sorted, distinct tax years to compare
+func (h *IncomeSalaryHandler) parseComparison(r *http.Request) (float64, []int, error) {
+	query := r.URL.Query()
+	var fields []models.FieldError
+
+	salary, field := h.parseSalaryParam("salary", query.Get("salary"))
+	if field != nil {
+		fields = append(fields, *field)
+	}
+
+	var years []int
+	list, from, to := query.Get("years"), query.Get("from"), query.Get("to")
+	switch {
+	case list != "" && (from != "" || to != ""):
+		fields = append(fields, models.FieldError{Field: "years", Code: validation.CodeInvalid, Message: "use either years or from and to, not both"})
+	case list != "":
+		seen := map[int]bool{}
+		for _, value := range strings.Split(list, ",") {
+			year, field := h.parseComparisonYear("years", strings.TrimSpace(value))
+			if field != nil {
+				fields = append(fields, *field)
+				break
+			}
+			if !seen[year] {
+				seen[year] = true
+				years = append(years, year)
+			}
+		}
+		sort.Ints(years)
+	case from != "" || to != "":
+		fromYear, fromField := h.parseComparisonYear("from", from)
+		toYear, toField := h.parseComparisonYear("to", to)
+		switch {
+		case fromField != nil:
+			fields = append(fields, *fromField)
+		case toField != nil:
+			fields = append(fields, *toField)
+		case fromYear > toYear:
+			fields = append(fields, models.FieldError{Field: "to", Code: validation.CodeOutOfRange, Message: fmt.Sprintf("to must not be before from (%d)", fromYear)})
+		default:
+			for year := fromYear; year <= toYear && len(years) <= maxComparisonYears; year++ {
+				years = append(years, year)
+			}
+		}
+	default:
+		fields = append(fields, models.FieldError{Field: "years", Code: validation.CodeRequired, Message: "years (a list) or from and to (a range) is required"})
+	}
+	if len(years) > maxComparisonYears {
+		fields = append(fields, models.FieldError{Field: "years", Code: validation.CodeOutOfRange, Message: fmt.Sprintf("at most %d years can be compared", maxComparisonYears)})
+	}
+
+	if len(fields) > 0 {
+		return 0, nil, newValidationError(fields...)
+	}
+	return salary, years, nil
+}
+
+// parseComparisonYear parses a tax year to compare. Unlike the year of a single
+// calculation, 0 (the upstream default year) is rejected: it names no year to compare.
+func (h *IncomeSalaryHandler) parseComparisonYear(name, value string) (int, *models.FieldError) {
+	year, field := h.parseYearParam(name, value)
+	if field == nil && year <= 0 {
+		return 0, &models.FieldError{Field: name, Code: validation.CodeInvalid, Message: fmt.Sprintf("%s must be a positive integer, got %q", name, value)}
+	}
+	return year, field
+}
+
+// roundRate rounds a rate difference to 4 decimals, hiding floating point noise
+func roundRate(rate float64) float64 {
+	return math.Round(rate*10000) / 10000
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+)
+
+func TestHandleComparison(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tax-year/2020":
+			fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":50000,"rate":0.2}]}`)
+		case "/tax-year/2022":
+			fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":50000,"rate":0.25}]}`)
+		case "/tax-year/2018":
+			fmt.Fprint(w, `{"jurisdictions":[{"code":"US","tax_brackets":[{"min":0,"rate":0.1}]}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	handler := NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: upstream.URL}, metrics.NewNoop())
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.HandleComparison(w, httptest.NewRequest("GET", "/v1/tax-comparison?"+query, nil))
+		return w
+	}
+
+	t.Run("Range with a failed year", func(t *testing.T) {
+		w := get("salary=60000&from=2020&to=2022")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+		}
+		var response models.ComparisonResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(response.Years) != 3 {
+			t.Fatalf("expected 3 years but got %+v", response.Years)
+		}
+
+		first, failed, last := response.Years[0], response.Years[1], response.Years[2]
+		if first.Year != 2020 || first.Tax != 7000 || first.MarginalRate != 0.2 || first.Change != nil {
+			t.Errorf("unexpected 2020 result %+v", first)
+		}
+		if failed.Year != 2021 || failed.Code != models.CodeTaxYearNotFound || failed.Error == "" {
+			t.Errorf("expected 2021 to fail with %q but got %+v", models.CodeTaxYearNotFound, failed)
+		}
+		// The change skips the failed year and compares with 2020
+		expected := models.YearChange{FromYear: 2020, Tax: 500, EffectiveRate: 0.008, MarginalRate: 0.05}
+		if last.Year != 2022 || last.Tax != 7500 || last.Change == nil || *last.Change != expected {
+			t.Errorf("expected 2022 to change by %+v but got %+v (change %+v)", expected, last, last.Change)
+		}
+	})
+
+	t.Run("Every year failed", func(t *testing.T) {
+		w := get("salary=60000&years=2019,2021")
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404 but got %d: %s", w.Code, w.Body.String())
+		}
+		if problem := decodeProblem(t, w); problem.Code != models.CodeTaxYearNotFound {
+			t.Errorf("expected code %q but got %q", models.CodeTaxYearNotFound, problem.Code)
+		}
+	})
+
+	t.Run("Tax rounded to cents", func(t *testing.T) {
+		w := get("salary=60000.33&years=2020,2022")
+		var response models.ComparisonResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(response.Years) != 2 || response.Years[0].Tax != 7000.07 || response.Years[1].Tax != 7500.08 {
+			t.Errorf("expected taxes 7000.07 and 7500.08 but got %+v", response.Years)
+		}
+	})
+
+	t.Run("Per-jurisdiction brackets only", func(t *testing.T) {
+		w := get("salary=60000&years=2018")
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected status 502 but got %d: %s", w.Code, w.Body.String())
+		}
+		if problem := decodeProblem(t, w); problem.Code != models.CodeUpstreamError {
+			t.Errorf("expected code %q but got %q", models.CodeUpstreamError, problem.Code)
+		}
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{
+			"salary=60000",
+			"years=2020,2022",
+			"salary=60000&years=2020,abc",
+			"salary=60000&years=2020&from=2020",
+			"salary=60000&from=2022&to=2020",
+			"salary=60000&from=1990&to=2020",
+			"salary=60000&from=2000&to=2025",
+			"salary=60000&years=0",
+			"salary=60000&years=0,2020",
+			"salary=60000&from=0&to=2020",
+			"salary=60000&from=2020&to=0",
+		} {
+			if w := get(query); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %s but got %d", query, w.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+	"pulsegrade/test1/validation"
+)
+
+// maxCurvePoints bounds the salaries requested for a curve (bracket breakpoints come on top)
+const maxCurvePoints = 2000
+
+// contentTypeCSV is the media type of CSV curve responses
+const contentTypeCSV = "text/csv"
+
+// HandleCurve processes GET /v1/tax-curve/{year}, returning the tax, effective
+// rate and marginal rate at a series of salaries from a single bracket fetch.
+// The salaries are a range (?from=0&to=200000&step=5000, from defaulting to 0)
+// or a list (?salaries=40000,80000); the bracket boundaries inside the series
+// are added as breakpoints so charts bend where the rate changes. The series
+// is JSON unless ?format=csv or an Accept header preferring text/csv asks for CSV.
+func (h *IncomeSalaryHandler) HandleCurve(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(r.PathValue("year"))
+	if err != nil || year <= 0 {
+		h.writeError(w, r, newValidationError(models.FieldError{Field: "year", Code: validation.CodeInvalid,
+			Message: fmt.Sprintf("year must be a positive integer, got %q", r.PathValue("year"))}))
+		return
+	}
+	if field := h.rules.TaxYear(year); field != nil {
+		h.writeError(w, r, newValidationError(*field))
+		return
+	}
+
+	salaries, err := h.parseCurve(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	asCSV, err := curveFormat(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	taxResponse, err := h.taxCalculator.FetchTaxData(r.Context(), services.TaxDataURL(h.config.TaxCalcBaseURL, year), year)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	points := h.curvePoints(salaries, taxResponse.TaxBrackets)
+
+	w.Header().Set("Vary", "Accept")
+	if asCSV {
+		w.Header().Set("Content-Type", contentTypeCSV+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"tax-curve-%d.csv\"", year))
+		writeCurveCSV(w, points)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CurveResponse{Year: year, Points: points})
+}
+
+// parseCurve reads the requested salaries, a range or a list, in ascending order
+func (h *IncomeSalaryHandler) parseCurve(r *http.Request) ([]float64, error) {
+	query := r.URL.Query()
+	list, to := query.Get("salaries"), query.Get("to")
+
+	switch {
+	case list != "" && (to != "" || query.Get("from") != "" || query.Get("step") != ""):
+		return nil, newValidationError(models.FieldError{Field: "salaries", Code: validation.CodeInvalid,
+			Message: "use either salaries or from, to and step, not both"})
+
+	case list != "":
+		values := strings.Split(list, ",")
+		if len(values) > maxCurvePoints {
+			return nil, newValidationError(models.FieldError{Field: "salaries", Code: validation.CodeOutOfRange,
+				Message: fmt.Sprintf("at most %d salaries can be requested", maxCurvePoints)})
+		}
+		salaries := make([]float64, 0, len(values))
+		for _, value := range values {
+			salary, field := h.parseSalaryParam("salaries", strings.TrimSpace(value))
+			if field != nil {
+				return nil, newValidationError(*field)
+			}
+			salaries = append(salaries, salary)
+		}
+		sort.Float64s(salaries)
+		return salaries, nil
+
+	case to != "":
+		var fields []models.FieldError
+		from := 0.0
+		if value := query.Get("from"); value != "" {
+			var field *models.FieldError
+			if from, field = h.parseSalaryParam("from", value); field != nil {
+				fields = append(fields, *field)
+			}
+		}
+		end, field := h.parseSalaryParam("to", to)
+		if field != nil {
+			fields = append(fields, *field)
+		}
+		step, err := strconv.ParseFloat(query.Get("step"), 64)
+		if err != nil || step <= 0 || math.IsInf(step, 0) {
+			fields = append(fields, models.FieldError{Field: "step", Code: validation.CodeInvalid,
+				Message: fmt.Sprintf("step must be a positive number, got %q", query.Get("step"))})
+		}
+		if len(fields) > 0 {
+			return nil, newValidationError(fields...)
+		}
+
+		switch {
+		case end < from:
+			return nil, newValidationError(models.FieldError{Field: "to", Code: validation.CodeOutOfRange,
+				Message: fmt.Sprintf("to must not be below from (%v)", from)})
+		case (end-from)/step >= maxCurvePoints:
+			return nil, newValidationError(models.FieldError{Field: "step", Code: validation.CodeOutOfRange,
+				Message: fmt.Sprintf("the range must have at most %d points; use a larger step", maxCurvePoints)})
+		}
+		var salaries []float64
+		for i := 0; from+float64(i)*step <= end; i++ {
+			salaries = append(salaries, from+float64(i)*step)
+		}
+		if salaries[len(salaries)-1] != end {
+			// Always end the curve at the requested maximum
+			salaries = append(salaries, end)
+		}
+		return salaries, nil
+
+	default:
+		return nil, newValidationError(models.FieldError{Field: "salaries", Code: validation.CodeRequired,
+			Message: "salaries (a list) or to and step (a range) is required"})
+	}
+}
+
+// curvePoints calculates each salary of the curve, adding the bracket boundaries
+// between the lowest and highest salary as breakpoints
+func (h *IncomeSalaryHandler) curvePoints(salaries []float64, brackets []models.TaxBracket) []models.CurvePoint {
+	low, high := salaries[0], salaries[len(salaries)-1]
+	requested := make(map[float64]bool, len(salaries))
+	for _, salary := range salaries {
+		requested[salary] = true
+	}
+
+	breakpoints := map[float64]bool{}
+	for _, bracket := range brackets {
+		for _, boundary := range []float64{bracket.Min, bracket.Max} {
+			if boundary > 0 && boundary >= low && boundary <= high {
+				breakpoints[boundary] = true
+				if !requested[boundary] {
+					requested[boundary] = true
+					salaries = append(salaries, boundary)
+				}
+			}
+		}
+	}
+	sort.Float64s(salaries)
+
+	points := make([]models.CurvePoint, 0, len(salaries))
+	for i, salary := range salaries {
+		if i > 0 && salary == salaries[i-1] {
+			continue // A salary listed twice is returned once
+		}
+		point := models.CurvePoint{Salary: salary, Breakpoint: breakpoints[salary]}
+		point.Tax, point.EffectiveRate = h.taxCalculator.CalculateTax(salary, brackets)
+		point.Tax = math.Round(point.Tax*100) / 100 // Rounded to cents in every format
+		point.MarginalRate = h.taxCalculator.MarginalRate(salary, brackets)
+		points = append(points, point)
+	}
+	return points
+}
+
+// curveFormat reports whether the curve is requested as CSV
+func curveFormat(r *http.Request) (bool, error) {
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case "csv":
+		return true, nil
+	case "json":
+		return false, nil
+	case "":
+	default:
+		return false, newValidationError(models.FieldError{Field: "format", Code: validation.CodeInvalid,
+			Message: fmt.Sprintf("format must be json or csv, got %q", format)})
+	}
+
+	// Without an explicit format, CSV is only returned when it is the first media type accepted
+	accept := strings.Split(r.Header.Get("Accept"), ",")[0]
+	mediaType, _, err := mime.ParseMediaType(accept)
+	return err == nil && mediaType == contentTypeCSV, nil
+}
+
+// writeCurveCSV writes the curve as CSV with a header row
+func writeCurveCSV(w http.ResponseWriter, points []models.CurvePoint) {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"salary", "tax", "effective_rate", "marginal_rate", "breakpoint"})
+	for _, point := range points {
+		writer.Write([]string{
+			strconv.FormatFloat(point.Salary, 'f', -1, 64),
+			strconv.FormatFloat(point.Tax, 'f', -1, 64),
+			strconv.FormatFloat(point.EffectiveRate, 'f', -1, 64),
+			strconv.FormatFloat(point.MarginalRate, 'f', -1, 64),
+			strconv.FormatBool(point.Breakpoint),
+		})
+	}
+	writer.Flush()
+}
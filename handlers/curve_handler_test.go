@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+)
+
+func TestHandleCurve(t *testing.T) {
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":45000,"rate":0.1},{"min":45000,"max":90000,"rate":0.2},{"min":90000,"rate":0.3}]}`)
+	}))
+	defer upstream.Close()
+
+	handler := NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: upstream.URL}, metrics.NewNoop())
+	get := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/tax-curve/2022?"+query, nil)
+		req.SetPathValue("year", "2022")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		handler.HandleCurve(w, req)
+		return w
+	}
+
+	t.Run("Range with breakpoints", func(t *testing.T) {
+		fetches.Store(0)
+		w := get("from=0&to=100000&step=20000", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+		}
+		var response models.CurveResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if fetches.Load() != 1 {
+			t.Errorf("expected a single bracket fetch but got %d", fetches.Load())
+		}
+
+		var salaries []float64
+		for _, point := range response.Points {
+			salaries = append(salaries, point.Salary)
+		}
+		expected := "[0 20000 40000 45000 60000 80000 90000 100000]"
+		if fmt.Sprint(salaries) != expected {
+			t.Fatalf("expected salaries %s but got %v", expected, salaries)
+		}
+
+		boundary := response.Points[3]
+		if !boundary.Breakpoint || boundary.Tax != 4500 || boundary.MarginalRate != 0.2 {
+			t.Errorf("unexpected breakpoint %+v", boundary)
+		}
+		if response.Points[0].EffectiveRate != 0 || response.Points[0].MarginalRate != 0.1 || response.Points[1].Breakpoint {
+			t.Errorf("unexpected points %+v", response.Points[:2])
+		}
+	})
+
+	t.Run("JSON and CSV round alike", func(t *testing.T) {
+		var response models.CurveResponse
+		if err := json.Unmarshal(get("salaries=50000.33", "").Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		rows, err := csv.NewReader(get("salaries=50000.33", "text/csv").Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to read CSV: %v", err)
+		}
+		// 4500 + 0.2 * 5000.33 = 5500.066
+		if len(response.Points) != 1 || response.Points[0].Tax != 5500.07 || rows[1][1] != "5500.07" {
+			t.Errorf("expected a tax of 5500.07 in both formats but got %+v and %v", response.Points, rows)
+		}
+	})
+
+	t.Run("List as CSV", func(t *testing.T) {
+		w := get("salaries=100000,50000,50000", "text/csv")
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+			t.Fatalf("expected CSV but got %q", w.Header().Get("Content-Type"))
+		}
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to read CSV: %v", err)
+		}
+		// Header, 50000, the 90000 breakpoint and 100000; the duplicate is dropped
+		if len(rows) != 4 || rows[0][0] != "salary" || rows[1][1] != "5500" || rows[2][4] != "true" {
+			t.Errorf("unexpected CSV %v", rows)
+		}
+		if w := get("salaries=50000&format=csv", ""); !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+			t.Errorf("expected format=csv to select CSV")
+		}
+	})
+
+	t.Run("Per-jurisdiction brackets only", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"jurisdictions":[{"code":"US","tax_brackets":[{"min":0,"rate":0.1}]}]}`)
+		}))
+		defer upstream.Close()
+
+		handler := NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: upstream.URL}, metrics.NewNoop())
+		req := httptest.NewRequest("GET", "/v1/tax-curve/2022?salaries=50000", nil)
+		req.SetPathValue("year", "2022")
+		w := httptest.NewRecorder()
+		handler.HandleCurve(w, req)
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected status 502 but got %d: %s", w.Code, w.Body.String())
+		}
+		if problem := decodeProblem(t, w); problem.Code != models.CodeUpstreamError {
+			t.Errorf("expected code %q but got %q", models.CodeUpstreamError, problem.Code)
+		}
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{
+			"",
+			"to=100000",
+			"to=100000&step=0",
+			"from=50000&to=10000&step=1000",
+			"to=100000&step=1",
+			"salaries=1,abc",
+			"salaries=1&to=100&step=1",
+			"salaries=1&format=xml",
+		} {
+			if w := get(query, ""); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %q but got %d", query, w.Code)
+			}
+		}
+	})
+}
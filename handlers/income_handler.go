@@ -1,128 +1,330 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
-	"time"
 
+	"pulsegrade/test1/health"
 	"pulsegrade/test1/metrics"
 	"pulsegrade/test1/models"
 	"pulsegrade/test1/services"
+	"pulsegrade/test1/tracing"
+	"pulsegrade/test1/validation"
 )
 
 // IncomeSalaryHandler handles income and salary tax calculations
 type IncomeSalaryHandler struct {
 	config        models.Config
 	taxCalculator *services.TaxCalculator
+	rules         *validation.Rules
 	environment   string
+	metrics       *metrics.Registry
 }
 
-// NewIncomeSalaryHandler creates a new income salary handler
-func NewIncomeSalaryHandler(config models.Config) *IncomeSalaryHandler {
+// NewIncomeSalaryHandler creates a new income salary handler recording metrics in the given registry
+func NewIncomeSalaryHandler(config models.Config, registry *metrics.Registry) *IncomeSalaryHandler {
 	return &IncomeSalaryHandler{
 		config:        config,
-		taxCalculator: services.NewTaxCalculatorWithFullConfig(config.Environment, config.CircuitBreakerEnabled, config.CircuitBreaker),
+		taxCalculator: newTaxCalculator(config, registry),
+		rules:         validation.New(config.Validation),
 		environment:   config.Environment,
+		metrics:       registry,
 	}
 }
 
+// newTaxCalculator creates the tax calculator described by the configuration
+func newTaxCalculator(config models.Config, registry *metrics.Registry) *services.TaxCalculator {
+	calculator := services.NewTaxCalculatorWithFullConfig(config.Environment, config.CircuitBreakerEnabled, config.CircuitBreaker, registry)
+	calculator.SetConnectionTracing(config.TaxCalcTraceConnections)
+	return calculator
+}
+
 // Handle processes income-salary requests
 func (h *IncomeSalaryHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Set content type
 	w.Header().Set("Content-Type", "application/json")
 
-	// Parse salary and year from URL query and/or request body
-	salary, year, err := h.parseSalary(r)
+	// Parse salary, year, claims and jurisdiction from URL query and/or request body
+	input, err := h.parseSalary(r)
 	if err != nil {
-		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		var reqErr *requestError
+		if errors.As(err, &reqErr) && reqErr.code == models.CodeUnsupportedMediaType {
+			w.Header().Set("Accept-Post", contentTypeJSON+", "+contentTypeForm)
+		}
+		h.writeError(w, r, err)
 		return
 	}
 
-	// Determine tax calculator URL based on configuration
-	taxCalcURL := h.config.TaxCalcBaseURL
-	if h.config.IncludeTaxYear {
-		// Use provided year if available, otherwise use current year
-		taxYear := time.Now().Year()
-		if year > 0 {
-			taxYear = year
-		}
-		taxCalcURL = fmt.Sprintf("%s/tax-year/%d", h.config.TaxCalcBaseURL, taxYear)
-	}
+	// Determine tax calculator URL based on configuration (the upstream default
+	// year unless the year is part of the URL)
+	taxYear := services.ResolveTaxYear(h.config.IncludeTaxYear, input.year)
 
-	// Forward request to tax calculator
-	taxResponse, err := h.taxCalculator.FetchTaxData(taxCalcURL)
+	// Forward request to tax calculator (errors are counted by the tax calculator)
+	taxResponse, err := h.taxCalculator.FetchJurisdictionTaxData(r.Context(), h.config.TaxCalcBaseURL, input.jurisdiction, taxYear)
 	if err != nil {
-		// Increment tax service error metric with environment label
-		metrics.TaxServiceErrors.WithLabelValues(h.environment).Inc()
-		h.respondWithError(w, http.StatusInternalServerError, "Error calculating tax: "+err.Error())
+		h.writeError(w, r, err)
+		return
+	}
+
+	// Deductions can only be checked against the year's allowances once they are known
+	if disallowed := services.DisallowedDeductions(input.claims, taxResponse); len(disallowed) > 0 {
+		fields := make([]models.FieldError, 0, len(disallowed))
+		for _, name := range disallowed {
+			fields = append(fields, models.FieldError{Field: "deductions." + name, Code: validation.CodeInvalid,
+				Message: fmt.Sprintf("%s deductions are not allowed in tax year %s", name, metrics.TaxYearLabel(taxYear))})
+		}
+		h.writeError(w, r, newValidationError(fields...))
 		return
 	}
 
-	// Calculate tax based on brackets and salary
-	tax, effectiveRate := h.taxCalculator.CalculateTax(salary, taxResponse.TaxBrackets)
+	// Calculate tax based on brackets, salary and claims, combining the schedules of a jurisdiction
+	_, span := tracing.Tracer().Start(r.Context(), "CalculateTax")
+	combined := h.taxCalculator.CalculateCombined(input.salary, input.claims, taxResponse)
+	net, marginal := combined.Total, combined.Marginal
+	span.End()
 
-	// Increment tax calculation metric with environment label
-	metrics.TaxCalculationTotal.WithLabelValues(h.environment).Inc()
+	// Record calculation metrics
+	h.recordCalculation(inputChannel(r), input.salary, taxYear, net.EffectiveRate)
 
 	// Respond to client
 	response := models.Response{
-		Salary:        salary,
-		Tax:           tax,
-		EffectiveRate: effectiveRate,
+		Salary:              input.salary,
+		Deductions:          net.Deductions,
+		TaxableIncome:       net.TaxableIncome,
+		GrossTax:            net.GrossTax,
+		Credits:             net.Credits,
+		NetTax:              net.NetTax,
+		Tax:                 net.NetTax,
+		EffectiveRate:       net.EffectiveRate,
+		MarginalRate:        marginal.Rate,
+		Bracket:             marginal.Bracket,
+		IncomeToNextBracket: marginal.IncomeToNextBracket,
+		TaxOnNextDollar:     marginal.TaxOnNextDollar,
+		TaxOnNextThousand:   marginal.TaxOnNextThousand,
+		Jurisdiction:        input.jurisdiction,
+	}
+	if len(taxResponse.Jurisdictions) > 0 {
+		response.Jurisdictions = combined.Jurisdictions
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *IncomeSalaryHandler) parseSalary(r *http.Request) (float64, int, error) {
-	// Try to get salary from URL parameters
-	salaryStr := r.URL.Query().Get("salary")
-	yearStr := r.URL.Query().Get("year")
+// recordCalculation records usage metrics for a completed tax calculation
+func (h *IncomeSalaryHandler) recordCalculation(channel string, salary float64, taxYear int, effectiveRate float64) {
+	yearLabel := metrics.TaxYearLabel(taxYear)
 
-	// If not in URL, try to get from request body
-	if salaryStr == "" || yearStr == "" {
-		if r.Method == http.MethodPost {
-			if err := r.ParseForm(); err != nil {
-				return 0, 0, fmt.Errorf("invalid form data: %v", err)
-			}
-			if salaryStr == "" {
-				salaryStr = r.PostForm.Get("salary")
-			}
-			if yearStr == "" {
-				yearStr = r.PostForm.Get("year")
-			}
+	h.metrics.TaxCalculationTotal.WithLabelValues(h.environment).Inc()
+	h.metrics.TaxCalculationsByYear.WithLabelValues(yearLabel, channel, h.environment).Inc()
+	h.metrics.TaxCalculationsBySalaryBand.WithLabelValues(metrics.SalaryBand(salary), h.environment).Inc()
+	h.metrics.TaxEffectiveRate.WithLabelValues(yearLabel, h.environment).Observe(effectiveRate)
+}
+
+// inputChannel reports whether the salary was supplied in the URL query, a POST form or a JSON body
+func inputChannel(r *http.Request) string {
+	if r.URL.Query().Get("salary") != "" {
+		return "query"
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == contentTypeJSON {
+		return "json"
+	}
+	return "form"
+}
+
+// defaultMaxBodyBytes limits calculation request bodies when no limit is configured
+const defaultMaxBodyBytes = 64 << 10
+
+// contentTypeForm is the media type of URL-encoded POST forms
+const contentTypeForm = "application/x-www-form-urlencoded"
+
+// calculationParams are the parameters of a calculation, read from the URL query or a POST body
+var calculationParams = []string{"salary", "year", "deductions", "dependents", "jurisdiction"}
+
+// calculationInput holds the parsed parameters of a calculation
+type calculationInput struct {
+	salary       float64
+	year         int // 0 for the default year
+	claims       models.Claims
+	jurisdiction string // Upper case; empty for the upstream default
+}
+
+func (h *IncomeSalaryHandler) parseSalary(r *http.Request) (calculationInput, error) {
+	var input calculationInput
+
+	// Try to get the parameters from URL parameters
+	params := url.Values{}
+	for _, name := range calculationParams {
+		if value := r.URL.Query().Get(name); value != "" {
+			params.Set(name, value)
 		}
 	}
 
-	// Check if we have a salary value
-	if salaryStr == "" {
-		return 0, 0, fmt.Errorf("salary parameter is required")
+	// If the salary is not in the URL, try to get the parameters from the request
+	// body; a request with a salary in the query may carry an unrelated body
+	var bodyDeductions map[string]float64
+	if params.Get("salary") == "" && r.Method == http.MethodPost {
+		body, deductions, err := h.parseBody(r)
+		if err != nil {
+			return input, err
+		}
+		for _, name := range calculationParams {
+			if params.Get(name) == "" && body.Get(name) != "" {
+				params.Set(name, body.Get(name))
+			}
+		}
+		if params.Get("deductions") == "" {
+			bodyDeductions = deductions
+		}
 	}
+	salaryStr, yearStr := params.Get("salary"), params.Get("year")
 
-	// Parse salary to float
-	salary, err := strconv.ParseFloat(salaryStr, 64)
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid salary format: %v", err)
+	// Check both fields so every invalid field is reported at once
+	var fields []models.FieldError
+
+	var field *models.FieldError
+	if input.salary, field = h.parseSalaryParam("salary", salaryStr); field != nil {
+		fields = append(fields, *field)
 	}
 
 	// Parse year if provided, otherwise default to 0
-	year := 0
 	if yearStr != "" {
-		year, err = strconv.Atoi(yearStr)
-		if err != nil {
-			return 0, 0, fmt.Errorf("invalid year format: %v", err)
+		if input.year, field = h.parseYearParam("year", yearStr); field != nil {
+			fields = append(fields, *field)
 		}
 	}
 
-	return salary, year, nil
+	// Deductions, dependents and the jurisdiction are optional
+	if value := params.Get("deductions"); value != "" {
+		var deductionFields []models.FieldError
+		input.claims.Deductions, deductionFields = h.rules.ParseDeductions(value)
+		fields = append(fields, deductionFields...)
+	} else if len(bodyDeductions) > 0 {
+		var deductionFields []models.FieldError
+		input.claims.Deductions, deductionFields = h.checkDeductions(bodyDeductions)
+		fields = append(fields, deductionFields...)
+	}
+	if value := params.Get("dependents"); value != "" {
+		if input.claims.Dependents, field = h.parseDependentsParam(value); field != nil {
+			fields = append(fields, *field)
+		}
+	}
+	if value := params.Get("jurisdiction"); value != "" {
+		if input.jurisdiction, field = h.parseJurisdictionParam(value); field != nil {
+			fields = append(fields, *field)
+		}
+	}
+
+	if len(fields) > 0 {
+		return calculationInput{}, newValidationError(fields...)
+	}
+	return input, nil
 }
 
-func (h *IncomeSalaryHandler) respondWithError(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
-	response := models.Response{
-		Error: message,
+// checkDeductions checks the deductions of a JSON body, reporting every invalid one
+func (h *IncomeSalaryHandler) checkDeductions(deductions map[string]float64) (map[string]float64, []models.FieldError) {
+	names := make([]string, 0, len(deductions))
+	for name := range deductions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fields []models.FieldError
+	for _, name := range names {
+		if field := h.rules.Deduction(name, deductions[name]); field != nil {
+			fields = append(fields, *field)
+		}
+	}
+	return deductions, fields
+}
+
+// parseBody reads the calculation parameters from a POST body according to its
+// Content-Type: a JSON models.Request or a URL-encoded form (also assumed when
+// no Content-Type is sent, as older clients do). The deductions of a JSON body
+// are returned as given rather than in the type:amount form of query and form input.
+func (h *IncomeSalaryHandler) parseBody(r *http.Request) (url.Values, map[string]float64, error) {
+	maxBytes := int64(h.config.Server.MaxBodyBytes)
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, maxBytes)
+
+	mediaType := contentTypeForm
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, nil, unsupportedMediaType(fmt.Sprintf("Invalid Content-Type %q", contentType))
+		}
+	}
+
+	switch mediaType {
+	case contentTypeJSON:
+		return decodeJSONRequest(r)
+	case contentTypeForm:
+		if err := r.ParseForm(); err != nil {
+			return nil, nil, bodyError(fmt.Errorf("invalid form data: %w", err))
+		}
+		return r.PostForm, nil, nil
+	default:
+		return nil, nil, unsupportedMediaType(fmt.Sprintf("Unsupported Content-Type %q; use %s or %s", mediaType, contentTypeJSON, contentTypeForm))
+	}
+}
+
+// decodeJSONRequest decodes a JSON models.Request body, rejecting unknown
+// fields and trailing data, and returns its fields as strings so they follow
+// the same validation as query and form parameters, except for the deductions
+func decodeJSONRequest(r *http.Request) (url.Values, map[string]float64, error) {
+	var request models.Request
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		if err == io.EOF {
+			return nil, nil, bodyError(fmt.Errorf("invalid JSON body: the body is empty"))
+		}
+		return nil, nil, bodyError(fmt.Errorf("invalid JSON body: %w", err))
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, nil, bodyError(fmt.Errorf("invalid JSON body: unexpected data after the request object"))
+	}
+
+	values := url.Values{}
+	if request.Salary != nil {
+		values.Set("salary", strconv.FormatFloat(*request.Salary, 'f', -1, 64))
+	}
+	if request.Year != 0 {
+		values.Set("year", strconv.Itoa(request.Year))
+	}
+	if request.Dependents != 0 {
+		values.Set("dependents", strconv.Itoa(request.Dependents))
+	}
+	if request.Jurisdiction != "" {
+		values.Set("jurisdiction", request.Jurisdiction)
+	}
+	return values, request.Deductions, nil
+}
+
+// RegisterHealthChecks registers the readiness checks for the handler's dependencies:
+// the upstream tax calculator (critical), the circuit breaker (critical) and, when
+// requests name a tax year, bracket data for the current tax year (non-critical)
+func (h *IncomeSalaryHandler) RegisterHealthChecks(checker *health.Checker) {
+	checker.Register("tax_calculator", true, func(ctx context.Context) error {
+		return h.taxCalculator.ProbeTaxData(ctx, h.config.TaxCalcBaseURL)
+	})
+
+	checker.Register("circuit_breaker", true, h.taxCalculator.CheckCircuitBreaker)
+
+	// Without includeTaxYear requests use the upstream default year, which the
+	// tax_calculator check already probes
+	if h.config.IncludeTaxYear {
+		checker.Register("current_year_brackets", false, func(ctx context.Context) error {
+			return h.taxCalculator.ProbeTaxData(ctx, services.TaxDataURL(h.config.TaxCalcBaseURL, services.ResolveTaxYear(true, 0)))
+		})
 	}
-	json.NewEncoder(w).Encode(response)
 }
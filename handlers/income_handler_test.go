@@ -1,19 +1,28 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"pulsegrade/test1/health"
+	"pulsegrade/test1/metrics"
 	"pulsegrade/test1/models"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestParseSalary(t *testing.T) {
 	// Create a handler instance to test
-	handler := NewIncomeSalaryHandler(models.Config{})
+	handler := NewIncomeSalaryHandler(models.Config{}, metrics.NewNoop())
 
 	tests := []struct {
 		name           string
@@ -69,6 +78,83 @@ func TestParseSalary(t *testing.T) {
 			expectedYear:   2023,
 			expectError:    false,
 		},
+		{
+			name: "Parse JSON body with salary and year",
+			requestSetup: func() *http.Request {
+				req := httptest.NewRequest("POST", "/income-salary", strings.NewReader(`{"salary": 85000.5, "year": 2022}`))
+				req.Header.Set("Content-Type", "application/json; charset=utf-8")
+				return req
+			},
+			expectedSalary: 85000.5,
+			expectedYear:   2022,
+			expectError:    false,
+		},
+		{
+			name: "Parse JSON body with year from URL query",
+			requestSetup: func() *http.Request {
+				req := httptest.NewRequest("POST", "/income-salary?year=2021", strings.NewReader(`{"salary": 60000}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			expectedSalary: 60000,
+			expectedYear:   2021,
+			expectError:    false,
+		},
+		{
+			name: "POST with query parameters and an empty JSON body",
+			requestSetup: func() *http.Request {
+				req := httptest.NewRequest("POST", "/income-salary?salary=50000&year=2024", nil)
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			expectedSalary: 50000,
+			expectedYear:   2024,
+			expectError:    false,
+		},
+		{
+			name: "POST with query parameters and an unrelated body",
+			requestSetup: func() *http.Request {
+				req := httptest.NewRequest("POST", "/income-salary?salary=50000", strings.NewReader("hello"))
+				req.Header.Set("Content-Type", "text/plain")
+				return req
+			},
+			expectedSalary: 50000,
+			expectedYear:   0,
+			expectError:    false,
+		},
+		{
+			name: "JSON body with unknown field",
+			requestSetup: func() *http.Request {
+				req := httptest.NewRequest("POST", "/income-salary", strings.NewReader(`{"salary": 60000, "bonus": 5000}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			expectedSalary: 0,
+			expectedYear:   0,
+			expectError:    true,
+		},
+		{
+			name: "JSON body with salary as a string",
+			requestSetup: func() *http.Request {
+				req := httptest.NewRequest("POST", "/income-salary", strings.NewReader(`{"salary": "60000"}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			expectedSalary: 0,
+			expectedYear:   0,
+			expectError:    true,
+		},
+		{
+			name: "JSON body with trailing data",
+			requestSetup: func() *http.Request {
+				req := httptest.NewRequest("POST", "/income-salary", strings.NewReader(`{"salary": 60000} {"salary": 1}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			expectedSalary: 0,
+			expectedYear:   0,
+			expectError:    true,
+		},
 		{
 			name: "Missing salary parameter",
 			requestSetup: func() *http.Request {
@@ -99,13 +185,51 @@ func TestParseSalary(t *testing.T) {
 			expectedYear:   0,
 			expectError:    true,
 		},
+		{
+			name: "Zero salary",
+			requestSetup: func() *http.Request {
+				return httptest.NewRequest("GET", "/income-salary?salary=0", nil)
+			},
+			expectedSalary: 0,
+			expectedYear:   0,
+			expectError:    false,
+		},
+		{
+			name: "Negative salary",
+			requestSetup: func() *http.Request {
+				return httptest.NewRequest("GET", "/income-salary?salary=-1", nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "NaN salary",
+			requestSetup: func() *http.Request {
+				return httptest.NewRequest("GET", "/income-salary?salary=NaN", nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "Infinite salary",
+			requestSetup: func() *http.Request {
+				return httptest.NewRequest("GET", "/income-salary?salary=1e999", nil)
+			},
+			expectError: true,
+		},
+		{
+			name: "Year out of range",
+			requestSetup: func() *http.Request {
+				return httptest.NewRequest("GET", "/income-salary?salary=50000&year=99999", nil)
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			req := tc.requestSetup()
 
-			salary, year, err := handler.parseSalary(req)
+			input, err := handler.parseSalary(req)
+			salary, year := input.salary, input.year
 
 			if tc.expectError && err == nil {
 				t.Errorf("expected error but got none")
@@ -149,8 +273,9 @@ func TestHandleIncomeSalary(t *testing.T) {
 		Port:           "8080",
 	}
 
-	// Create a handler with our test config
-	handler := NewIncomeSalaryHandler(cfg)
+	// Create a handler with our test config and an isolated metrics registry
+	registry := metrics.NewRegistry(metrics.Options{})
+	handler := NewIncomeSalaryHandler(cfg, registry)
 
 	// Create a request to our handler
 	req := httptest.NewRequest("GET", "/income-salary?salary=75000", nil)
@@ -186,4 +311,265 @@ func TestHandleIncomeSalary(t *testing.T) {
 	if response.EffectiveRate != expectedEffectiveRate {
 		t.Errorf("expected effective rate %f but got %f", expectedEffectiveRate, response.EffectiveRate)
 	}
+
+	// Check the marginal details: 75000 is in the open-ended top bracket
+	if response.MarginalRate != 0.25 {
+		t.Errorf("expected marginal rate 0.25 but got %v", response.MarginalRate)
+	}
+	if response.Bracket == nil || response.Bracket.Index != 1 || response.Bracket.Min != 50000 {
+		t.Errorf("expected the second bracket starting at 50000 but got %+v", response.Bracket)
+	}
+	if response.IncomeToNextBracket != nil {
+		t.Errorf("expected no next bracket but got %v", *response.IncomeToNextBracket)
+	}
+	if response.TaxOnNextThousand != 250 {
+		t.Errorf("expected 250 tax on the next thousand but got %v", response.TaxOnNextThousand)
+	}
+
+	// Check calculation metrics were recorded in the handler's registry
+	if count := registry.CounterValue("taxapp_tax_calculations_total", nil); count != 1 {
+		t.Errorf("expected 1 tax calculation recorded but got %v", count)
+	}
+
+	if count := registry.CounterValue("taxapp_tax_calculations_by_year_total", prometheus.Labels{"tax_year": "default", "channel": "query"}); count != 1 {
+		t.Errorf("expected 1 query calculation for the default tax year but got %v", count)
+	}
+}
+
+func TestHandleIncomeSalaryBodyErrors(t *testing.T) {
+	handler := NewIncomeSalaryHandler(models.Config{Server: models.ServerConfig{MaxBodyBytes: 64}}, metrics.NewNoop())
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{name: "Unsupported media type", contentType: "text/plain", body: "salary=50000", status: http.StatusUnsupportedMediaType, code: models.CodeUnsupportedMediaType},
+		{name: "Multipart form", contentType: "multipart/form-data; boundary=x", body: "--x--", status: http.StatusUnsupportedMediaType, code: models.CodeUnsupportedMediaType},
+		{name: "Body too large", contentType: "application/json", body: `{"salary": 50000, "year": 2022` + strings.Repeat(" ", 64) + `}`, status: http.StatusRequestEntityTooLarge, code: models.CodePayloadTooLarge},
+		{name: "Malformed JSON", contentType: "application/json", body: `{"salary": `, status: http.StatusBadRequest, code: models.CodeInvalidBody},
+		{name: "Empty JSON body", contentType: "application/json", body: "", status: http.StatusBadRequest, code: models.CodeInvalidBody},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/income-salary", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			handler.Handle(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("expected status %d but got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Post") == "" {
+				t.Errorf("expected an Accept-Post header listing the supported media types")
+			}
+			if problem := decodeProblem(t, w); problem.Code != tc.code {
+				t.Errorf("expected code %q but got %q", tc.code, problem.Code)
+			}
+		})
+	}
+}
+
+func TestHandleIncomeSalaryClaims(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.TaxCalculatorResponse{
+			TaxBrackets: []models.TaxBracket{
+				{Min: 0, Max: 50000, Rate: 0.15},
+				{Min: 50000, Rate: 0.25},
+			},
+			Allowances: &models.Allowances{
+				CreditRate:          0.15,
+				BasicPersonalAmount: 15000,
+				DependentAmount:     2000,
+				Deductions:          map[string]models.DeductionLimit{"retirement": {Max: 10000}, "union_dues": {}},
+			},
+		})
+	}))
+	defer mockServer.Close()
+
+	handler := NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: mockServer.URL}, metrics.NewNoop())
+
+	// JSON body and query parameters describe the same claims
+	requests := map[string]*http.Request{
+		"query": httptest.NewRequest("GET", "/income-salary?salary=75000&deductions=retirement:12000,union_dues:600&dependents=2", nil),
+		"json":  httptest.NewRequest("POST", "/income-salary", strings.NewReader(`{"salary": 75000, "deductions": {"retirement": 12000, "union_dues": 600}, "dependents": 2}`)),
+	}
+	requests["json"].Header.Set("Content-Type", "application/json")
+
+	for name, req := range requests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.Handle(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+			}
+
+			var response models.Response
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			// Retirement is capped at 10000: 64400 taxable, 11100 gross, (15000 + 2 * 2000) * 0.15 = 2850 of credits
+			if response.Deductions != 10600 || response.TaxableIncome != 64400 || response.GrossTax != 11100 || response.Credits != 2850 || response.NetTax != 8250 {
+				t.Errorf("unexpected calculation: %+v", response)
+			}
+			if response.Tax != response.NetTax {
+				t.Errorf("expected tax to equal the net tax but got %v and %v", response.Tax, response.NetTax)
+			}
+		})
+	}
+
+	t.Run("disallowed deduction", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.Handle(w, httptest.NewRequest("GET", "/income-salary?salary=75000&deductions=childcare:5000", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 but got %d: %s", w.Code, w.Body.String())
+		}
+		if problem := decodeProblem(t, w); len(problem.Errors) != 1 || problem.Errors[0].Field != "deductions.childcare" {
+			t.Errorf("expected a field error for deductions.childcare but got %+v", problem.Errors)
+		}
+	})
+
+	t.Run("invalid claims", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.Handle(w, httptest.NewRequest("GET", "/income-salary?salary=75000&deductions=retirement:-5&dependents=x", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 but got %d: %s", w.Code, w.Body.String())
+		}
+		if problem := decodeProblem(t, w); len(problem.Errors) != 2 {
+			t.Errorf("expected field errors for deductions and dependents but got %+v", problem.Errors)
+		}
+	})
+
+	t.Run("invalid JSON deduction type", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/income-salary", strings.NewReader(`{"salary": 75000, "deductions": {"a,b:c": 100}}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 but got %d: %s", w.Code, w.Body.String())
+		}
+		problem := decodeProblem(t, w)
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != "deductions" || !strings.Contains(problem.Errors[0].Message, `"a,b:c"`) {
+			t.Errorf("expected a single error naming the deduction type but got %+v", problem.Errors)
+		}
+	})
+}
+
+func TestHandleIncomeSalaryJurisdiction(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jurisdiction/US-CA/tax-year/2022" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(models.TaxCalculatorResponse{Jurisdictions: []models.JurisdictionSchedule{
+			{Code: "US", Name: "Federal", TaxBrackets: []models.TaxBracket{{Min: 0, Max: 50000, Rate: 0.1}, {Min: 50000, Rate: 0.2}}},
+			{Code: "US-CA", Name: "California", TaxBrackets: []models.TaxBracket{{Min: 0, Max: 60000, Rate: 0.05}, {Min: 60000, Rate: 0.08}}},
+		}})
+	}))
+	defer mockServer.Close()
+
+	cfg := models.Config{TaxCalcBaseURL: mockServer.URL, IncludeTaxYear: true, IncludeJurisdiction: true}
+	handler := NewIncomeSalaryHandler(cfg, metrics.NewNoop())
+
+	w := httptest.NewRecorder()
+	handler.Handle(w, httptest.NewRequest("GET", "/income-salary?salary=55000&year=2022&jurisdiction=us-ca", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response models.Response
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Jurisdiction != "US-CA" || len(response.Jurisdictions) != 2 {
+		t.Fatalf("expected the US and US-CA schedules but got %+v", response)
+	}
+	// Federal 6000 plus state 2750, taxed at 0.2 + 0.05 on the next dollar
+	if response.Jurisdictions[0].NetTax != 6000 || response.Jurisdictions[1].NetTax != 2750 || response.NetTax != 8750 {
+		t.Errorf("unexpected taxes: %+v", response)
+	}
+	if response.MarginalRate != 0.25 || response.Bracket != nil {
+		t.Errorf("expected a combined marginal rate of 0.25 without a single bracket but got %v and %+v", response.MarginalRate, response.Bracket)
+	}
+
+	// Jurisdictions are rejected unless the server requests them upstream
+	cfg.IncludeJurisdiction = false
+	w = httptest.NewRecorder()
+	NewIncomeSalaryHandler(cfg, metrics.NewNoop()).Handle(w, httptest.NewRequest("GET", "/income-salary?salary=55000&jurisdiction=US-CA", nil))
+	if problem := decodeProblem(t, w); w.Code != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != "jurisdiction" {
+		t.Errorf("expected a jurisdiction field error but got %d: %+v", w.Code, problem)
+	}
+}
+
+func TestRegisterHealthChecks(t *testing.T) {
+	// The mock tax calculator only has brackets for its default year, and invalid
+	// brackets for 2020
+	currentYear := fmt.Sprintf("/tax-year/%d", time.Now().Year())
+	var paths []string
+	var mu sync.Mutex
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			json.NewEncoder(w).Encode(models.TaxCalculatorResponse{
+				TaxBrackets: []models.TaxBracket{{Min: 0, Rate: 0.15}},
+			})
+		case "/tax-year/2020":
+			fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.1},{"min":40000,"rate":0.2}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	cfg := models.Config{TaxCalcBaseURL: mockServer.URL, CircuitBreakerEnabled: true, IncludeTaxYear: true}
+	handler := NewIncomeSalaryHandler(cfg, metrics.NewNoop())
+
+	checker := health.NewChecker(time.Second)
+	handler.RegisterHealthChecks(checker)
+
+	t.Run("Missing current year data is degraded", func(t *testing.T) {
+		report := checker.Run(context.Background())
+		if report.Status != health.StatusDegraded {
+			t.Errorf("expected status %s but got %s: %+v", health.StatusDegraded, report.Status, report.Checks)
+		}
+		if !slices.Contains(paths, currentYear) {
+			t.Errorf("expected %s to be probed but got %v", currentYear, paths)
+		}
+	})
+
+	t.Run("Current year check needs includeTaxYear", func(t *testing.T) {
+		checker := health.NewChecker(time.Second)
+		NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: mockServer.URL}, metrics.NewNoop()).RegisterHealthChecks(checker)
+		report := checker.Run(context.Background())
+		if report.Status != health.StatusOK || len(report.Checks) != 2 {
+			t.Errorf("expected 2 passing checks but got %s: %+v", report.Status, report.Checks)
+		}
+	})
+
+	t.Run("Invalid tax data is unavailable", func(t *testing.T) {
+		checker := health.NewChecker(time.Second)
+		NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: mockServer.URL + "/tax-year/2020"}, metrics.NewNoop()).RegisterHealthChecks(checker)
+		report := checker.Run(context.Background())
+		if report.Status != health.StatusUnavailable {
+			t.Errorf("expected status %s but got %s: %+v", health.StatusUnavailable, report.Status, report.Checks)
+		}
+	})
+
+	t.Run("Unreachable upstream is unavailable", func(t *testing.T) {
+		mockServer.Close()
+		if report := checker.Run(context.Background()); report.Status != health.StatusDegraded {
+			t.Errorf("expected the cached probe results to be reused but got %s", report.Status)
+		}
+
+		handler.taxCalculator.SetProbeCacheTTL(0)
+		report := checker.Run(context.Background())
+		if report.Status != health.StatusUnavailable {
+			t.Errorf("expected status %s but got %s", health.StatusUnavailable, report.Status)
+		}
+	})
 }
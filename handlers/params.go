@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/validation"
+)
+
+// parseSalaryParam parses a required salary parameter and checks it is within
+// the salary bounds; name is the parameter reported in the field error
+func (h *IncomeSalaryHandler) parseSalaryParam(name, value string) (float64, *models.FieldError) {
+	if value == "" {
+		return 0, &models.FieldError{Field: name, Code: validation.CodeRequired, Message: name + " is required"}
+	}
+	salary, err := strconv.ParseFloat(value, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, &models.FieldError{Field: name, Code: validation.CodeInvalid, Message: fmt.Sprintf("%s must be a number, got %q", name, value)}
+	}
+	// Out of range values such as 1e999 parse as ±Inf and are rejected by the rules
+	if field := h.rules.Salary(salary); field != nil {
+		field.Field = name
+		return 0, field
+	}
+	return salary, nil
+}
+
+// parseYearParam parses a tax year parameter and checks it is a supported year;
+// name is the parameter reported in the field error
+func (h *IncomeSalaryHandler) parseYearParam(name, value string) (int, *models.FieldError) {
+	year, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &models.FieldError{Field: name, Code: validation.CodeInvalid, Message: fmt.Sprintf("%s must be an integer, got %q", name, value)}
+	}
+	if field := h.rules.TaxYear(year); field != nil {
+		field.Field = name
+		return 0, field
+	}
+	return year, nil
+}
+
+// parseDependentsParam parses the number of dependents claimed
+func (h *IncomeSalaryHandler) parseDependentsParam(value string) (int, *models.FieldError) {
+	dependents, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &models.FieldError{Field: "dependents", Code: validation.CodeInvalid, Message: fmt.Sprintf("dependents must be an integer, got %q", value)}
+	}
+	return dependents, h.rules.Dependents(dependents)
+}
+
+// parseJurisdictionParam parses a jurisdiction code, which is only accepted when
+// the tax calculator is called per jurisdiction (includeJurisdiction)
+func (h *IncomeSalaryHandler) parseJurisdictionParam(value string) (string, *models.FieldError) {
+	if !h.config.IncludeJurisdiction {
+		return "", &models.FieldError{Field: "jurisdiction", Code: validation.CodeInvalid, Message: "jurisdictions are not enabled on this server"}
+	}
+	code := strings.ToUpper(strings.TrimSpace(value))
+	return code, h.rules.Jurisdiction(code)
+}
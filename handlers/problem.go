@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+)
+
+// ContentTypeProblem is the media type of RFC 7807 error responses
+const ContentTypeProblem = "application/problem+json"
+
+// problemTypePrefix turns an error code into the problem type URI
+const problemTypePrefix = "urn:taxapp:problem:"
+
+// problemTitles holds the title of each problem type
+var problemTitles = map[string]string{
+	models.CodeValidation:           "Invalid request parameters",
+	models.CodeInvalidBody:          "Malformed request body",
+	models.CodeUnsupportedMediaType: "Unsupported media type",
+	models.CodePayloadTooLarge:      "Request too large",
+	models.CodeMethodNotAllowed:     "Method not allowed",
+	models.CodeTaxYearNotFound:      "Tax year not found",
+	models.CodeJurisdictionNotFound: "Jurisdiction not found",
+	models.CodeUpstreamUnavailable:  "Tax calculator unavailable",
+	models.CodeUpstreamError:        "Tax calculator error",
+	models.CodeInternal:             "Internal server error",
+}
+
+// requestError is a client error carrying the problem to report
+type requestError struct {
+	status int
+	code   string
+	detail string
+	fields []models.FieldError
+}
+
+// Error returns the problem detail
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+// newValidationError reports invalid fields
+func newValidationError(fields ...models.FieldError) *requestError {
+	detail := "The request has invalid parameters"
+	if len(fields) == 1 {
+		detail = fields[0].Message
+	}
+	return &requestError{status: http.StatusBadRequest, code: models.CodeValidation, detail: detail, fields: fields}
+}
+
+// WriteProblem writes an RFC 7807 problem response
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, fields ...models.FieldError) {
+	problem := models.Problem{
+		Type:      problemTypePrefix + code,
+		Title:     problemTitles[code],
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logger.RequestIDFromContext(r.Context()),
+		Errors:    fields,
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// writeError reports err as a problem. Client errors are described as they are;
+// upstream and unexpected errors are logged with the request ID and reported
+// with a generic detail so internal error text never reaches clients.
+func (h *IncomeSalaryHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		WriteProblem(w, r, reqErr.status, reqErr.code, reqErr.detail, reqErr.fields...)
+		return
+	}
+
+	logger.Error("%s %s failed (request_id=%s): %v", r.Method, r.URL.Path, logger.RequestIDFromContext(r.Context()), err)
+
+	switch code := services.ErrorCode(err); code {
+	case models.CodeUpstreamUnavailable:
+		if h.config.CircuitBreaker.Timeout > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(h.config.CircuitBreaker.Timeout))
+		}
+		WriteProblem(w, r, http.StatusServiceUnavailable, code, "The tax calculator is temporarily unavailable; retry later")
+	case models.CodeTaxYearNotFound:
+		WriteProblem(w, r, http.StatusNotFound, code, "No tax brackets are available for the requested year")
+	case models.CodeJurisdictionNotFound:
+		WriteProblem(w, r, http.StatusNotFound, code, "No tax brackets are available for the requested jurisdiction and year")
+	default:
+		WriteProblem(w, r, http.StatusBadGateway, code, "The tax calculator failed to provide tax brackets")
+	}
+}
+
+// unsupportedMediaType reports a request body of the wrong Content-Type
+func unsupportedMediaType(detail string) *requestError {
+	return &requestError{status: http.StatusUnsupportedMediaType, code: models.CodeUnsupportedMediaType, detail: detail}
+}
+
+// bodyError converts an error reading a request body into the problem to report
+func bodyError(err error) *requestError {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &requestError{status: http.StatusRequestEntityTooLarge, code: models.CodePayloadTooLarge,
+			detail: fmt.Sprintf("The request body exceeds %d bytes", maxBytesErr.Limit)}
+	}
+	return &requestError{status: http.StatusBadRequest, code: models.CodeInvalidBody, detail: err.Error()}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/services"
+)
+
+// decodeProblem checks that a response is a problem and decodes it
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+
+	if contentType := w.Header().Get("Content-Type"); contentType != ContentTypeProblem {
+		t.Fatalf("expected Content-Type %s but got %q", ContentTypeProblem, contentType)
+	}
+	var problem models.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	if problem.Status != w.Code {
+		t.Errorf("expected problem status %d to match the response status %d", problem.Status, w.Code)
+	}
+	if problem.Type != "urn:taxapp:problem:"+problem.Code || problem.Title == "" {
+		t.Errorf("expected a type and title for code %q but got %q and %q", problem.Code, problem.Type, problem.Title)
+	}
+	return problem
+}
+
+func TestValidationProblem(t *testing.T) {
+	handler := NewIncomeSalaryHandler(models.Config{}, metrics.NewNoop())
+
+	req := httptest.NewRequest("GET", "/v1/income-salary?salary=abc&year=next", nil)
+	req = req.WithContext(logger.WithRequestID(req.Context(), "req-123"))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 but got %d", w.Code)
+	}
+	problem := decodeProblem(t, w)
+	if problem.Code != models.CodeValidation || problem.RequestID != "req-123" || problem.Instance != "/v1/income-salary" {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+
+	// Both invalid fields are reported at once
+	fields := map[string]string{}
+	for _, field := range problem.Errors {
+		fields[field.Field] = field.Code
+	}
+	if len(fields) != 2 || fields["salary"] != "invalid" || fields["year"] != "invalid" {
+		t.Errorf("expected salary and year field errors but got %+v", problem.Errors)
+	}
+}
+
+func TestUpstreamProblems(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[{"code":"NOT_FOUND","message":"secret upstream detail"}]}`)
+	}
+
+	tests := []struct {
+		name     string
+		query    string // Added to the salary; a year or jurisdiction is put in the upstream URL
+		upstream http.HandlerFunc
+		status   int
+		code     string
+	}{
+		{
+			name:     "Tax year not found",
+			query:    "&year=2022",
+			upstream: notFound,
+			status:   http.StatusNotFound,
+			code:     models.CodeTaxYearNotFound,
+		},
+		{
+			name:     "Jurisdiction not found",
+			query:    "&year=2022&jurisdiction=US-ZZ",
+			upstream: notFound,
+			status:   http.StatusNotFound,
+			code:     models.CodeJurisdictionNotFound,
+		},
+		{
+			// The client asked for no year and the upstream default year is used: a missing default is the tax calculator's fault
+			name:     "Default year not found",
+			upstream: notFound,
+			status:   http.StatusBadGateway,
+			code:     models.CodeUpstreamError,
+		},
+		{
+			name: "Upstream failure",
+			upstream: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"errors":[{"code":"INTERNAL","message":"secret upstream detail"}]}`)
+			},
+			status: http.StatusBadGateway,
+			code:   models.CodeUpstreamError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := httptest.NewServer(tc.upstream)
+			defer upstream.Close()
+
+			config := models.Config{TaxCalcBaseURL: upstream.URL, IncludeTaxYear: tc.query != "", IncludeJurisdiction: true}
+			handler := NewIncomeSalaryHandler(config, metrics.NewNoop())
+			w := httptest.NewRecorder()
+			handler.Handle(w, httptest.NewRequest("GET", "/v1/income-salary?salary=50000"+tc.query, nil))
+
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d but got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if problem := decodeProblem(t, w); problem.Code != tc.code {
+				t.Errorf("expected code %q but got %q", tc.code, problem.Code)
+			}
+			if strings.Contains(w.Body.String(), "secret upstream detail") {
+				t.Errorf("upstream error details leaked to the client: %s", w.Body.String())
+			}
+		})
+	}
+
+	t.Run("Connection refused", func(t *testing.T) {
+		handler := NewIncomeSalaryHandler(models.Config{TaxCalcBaseURL: "http://127.0.0.1:1"}, metrics.NewNoop())
+		w := httptest.NewRecorder()
+		handler.Handle(w, httptest.NewRequest("GET", "/v1/income-salary?salary=50000", nil))
+
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected status 502 but got %d", w.Code)
+		}
+		if strings.Contains(w.Body.String(), "dial tcp") || strings.Contains(w.Body.String(), "127.0.0.1") {
+			t.Errorf("network error details leaked to the client: %s", w.Body.String())
+		}
+	})
+
+	t.Run("Circuit open", func(t *testing.T) {
+		config := models.Config{CircuitBreaker: models.CircuitBreakerConfig{Timeout: 30}}
+		handler := NewIncomeSalaryHandler(config, metrics.NewNoop())
+		w := httptest.NewRecorder()
+		err := fmt.Errorf("%w (circuit open): too many failures", services.ErrUnavailable)
+		handler.writeError(w, httptest.NewRequest("GET", "/v1/income-salary", nil), err)
+
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "30" {
+			t.Errorf("expected status 503 with Retry-After 30 but got %d and %q", w.Code, w.Header().Get("Retry-After"))
+		}
+		if problem := decodeProblem(t, w); problem.Code != models.CodeUpstreamUnavailable {
+			t.Errorf("expected code %q but got %q", models.CodeUpstreamUnavailable, problem.Code)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"pulsegrade/test1/version"
+)
+
+// VersionResponse is the body returned by the version endpoint
+type VersionResponse struct {
+	version.Info
+	Environment string `json:"environment"`
+	StartTime   string `json:"start_time"`
+	Uptime      string `json:"uptime"`
+}
+
+// VersionHandler reports which build of the application is running
+type VersionHandler struct {
+	info        version.Info
+	environment string
+	startTime   time.Time
+}
+
+// NewVersionHandler creates a new version handler for a process started at startTime
+func NewVersionHandler(environment string, startTime time.Time) *VersionHandler {
+	return &VersionHandler{
+		info:        version.Get(),
+		environment: environment,
+		startTime:   startTime,
+	}
+}
+
+// Handle processes version requests
+func (h *VersionHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := VersionResponse{
+		Info:        h.info,
+		Environment: h.environment,
+		StartTime:   h.startTime.UTC().Format(time.RFC3339),
+		Uptime:      time.Since(h.startTime).Round(time.Second).String(),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestVersionHandler(t *testing.T) {
+	handler := NewVersionHandler("test", time.Now().Add(-time.Minute))
+
+	w := httptest.NewRecorder()
+	handler.Handle(w, httptest.NewRequest("GET", "/version", nil))
+
+	var response VersionResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Environment != "test" {
+		t.Errorf("expected environment test but got %s", response.Environment)
+	}
+	if response.GoVersion != runtime.Version() {
+		t.Errorf("expected Go version %s but got %s", runtime.Version(), response.GoVersion)
+	}
+	if response.Version == "" || response.Commit == "" {
+		t.Errorf("expected version and commit to be reported but got %+v", response)
+	}
+	if response.Uptime != "1m0s" {
+		t.Errorf("expected uptime 1m0s but got %s", response.Uptime)
+	}
+}
@@ -0,0 +1,198 @@
+// Package health implements liveness and readiness checks for the application
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds how long a single dependency check may take
+const DefaultTimeout = 2 * time.Second
+
+// Overall and per-check statuses reported in the JSON detail view
+const (
+	StatusOK          = "ok"            // Every check passed
+	StatusDegraded    = "degraded"      // Only non-critical checks failed; the application still serves traffic
+	StatusUnavailable = "unavailable"   // A critical check failed
+	StatusShutdown    = "shutting_down" // The application is draining and no longer accepts new traffic
+	StatusFail        = "fail"          // Status of an individual failed check
+)
+
+// CheckFunc checks a single dependency, returning an error when it is unhealthy
+type CheckFunc func(ctx context.Context) error
+
+// Result is the outcome of the most recent run of a check
+type Result struct {
+	Name            string     `json:"name"`
+	Status          string     `json:"status"`
+	Critical        bool       `json:"critical"`
+	LatencyMs       float64    `json:"latency_ms"`
+	Error           string     `json:"error,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorTime   *time.Time `json:"last_error_time,omitempty"`
+	LastSuccessTime *time.Time `json:"last_success_time,omitempty"`
+}
+
+// Report is the JSON body returned by the readiness endpoint
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// check is a registered dependency check along with its history
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+
+	mu              sync.Mutex
+	lastError       string
+	lastErrorTime   time.Time
+	lastSuccessTime time.Time
+}
+
+// Checker runs the registered dependency checks. Checks can be registered at any
+// time, so components add their own dependencies as they are created.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []*check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewChecker creates a Checker that gives each check at most timeout to complete
+// (DefaultTimeout when timeout is not positive)
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a named dependency check. A failing critical check makes the
+// application not ready; a failing non-critical check only marks it degraded.
+func (c *Checker) Register(name string, critical bool, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, &check{name: name, critical: critical, fn: fn})
+}
+
+// SetShuttingDown marks the application as not ready regardless of its checks,
+// so load balancers stop routing new requests before the server drains
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Run executes all checks concurrently and returns the combined report
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]*check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, chk *check) {
+			defer wg.Done()
+			results[i] = chk.run(ctx, c.timeout)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusUnavailable
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+// run executes the check with a timeout and updates its history
+func (chk *check) run(ctx context.Context, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	err := chk.fn(ctx)
+	latency := time.Since(startTime)
+
+	chk.mu.Lock()
+	defer chk.mu.Unlock()
+
+	result := Result{
+		Name:      chk.name,
+		Status:    StatusOK,
+		Critical:  chk.critical,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+		chk.lastError = err.Error()
+		chk.lastErrorTime = startTime
+	} else {
+		chk.lastSuccessTime = startTime
+	}
+
+	if chk.lastError != "" {
+		lastErrorTime := chk.lastErrorTime
+		result.LastError = chk.lastError
+		result.LastErrorTime = &lastErrorTime
+	}
+	if !chk.lastSuccessTime.IsZero() {
+		lastSuccessTime := chk.lastSuccessTime
+		result.LastSuccessTime = &lastSuccessTime
+	}
+
+	return result
+}
+
+// LivenessHandler reports that the process is alive and able to serve HTTP requests.
+// It never checks dependencies, so an unavailable upstream does not get the process restarted.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+	})
+}
+
+// ReadinessHandler runs every check and responds with the JSON report:
+// 200 when ok or degraded, 503 when a critical check failed or the application is shutting down
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.shuttingDown.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, Report{Status: StatusShutdown, Checks: []Result{}})
+			return
+		}
+
+		report := c.Run(r.Context())
+
+		statusCode := http.StatusOK
+		if report.Status == StatusUnavailable {
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeJSON(w, statusCode, report)
+	})
+}
+
+// writeJSON writes body as an uncacheable JSON response
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		criticalErr        error
+		optionalErr        error
+		expectedStatus     string
+		expectedStatusCode int
+	}{
+		{name: "All checks pass", expectedStatus: StatusOK, expectedStatusCode: http.StatusOK},
+		{name: "Non-critical check fails", optionalErr: errors.New("no data"), expectedStatus: StatusDegraded, expectedStatusCode: http.StatusOK},
+		{name: "Critical check fails", criticalErr: errors.New("unreachable"), expectedStatus: StatusUnavailable, expectedStatusCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checker := NewChecker(time.Second)
+			checker.Register("upstream", true, func(ctx context.Context) error { return tc.criticalErr })
+			checker.Register("data", false, func(ctx context.Context) error { return tc.optionalErr })
+
+			w := httptest.NewRecorder()
+			checker.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+			if w.Code != tc.expectedStatusCode {
+				t.Errorf("expected status code %d but got %d", tc.expectedStatusCode, w.Code)
+			}
+
+			var report Report
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode report: %v", err)
+			}
+			if report.Status != tc.expectedStatus {
+				t.Errorf("expected status %s but got %s", tc.expectedStatus, report.Status)
+			}
+			if len(report.Checks) != 2 {
+				t.Errorf("expected 2 checks in report but got %d", len(report.Checks))
+			}
+		})
+	}
+}
+
+func TestCheckRemembersLastError(t *testing.T) {
+	checker := NewChecker(time.Second)
+	var err error = errors.New("connection refused")
+	checker.Register("upstream", true, func(ctx context.Context) error { return err })
+
+	checker.Run(context.Background())
+	err = nil
+	report := checker.Run(context.Background())
+
+	result := report.Checks[0]
+	if result.Status != StatusOK || result.Error != "" {
+		t.Errorf("expected check to pass but got %+v", result)
+	}
+	if result.LastError != "connection refused" || result.LastErrorTime == nil {
+		t.Errorf("expected last error to be remembered but got %+v", result)
+	}
+	if result.LastSuccessTime == nil {
+		t.Errorf("expected last success time to be set")
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Register("slow", true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if report := checker.Run(context.Background()); report.Status != StatusUnavailable {
+		t.Errorf("expected timed out check to make the application unavailable but got %s", report.Status)
+	}
+}
+
+func TestLivenessHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	LivenessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status code 200 but got %d", w.Code)
+	}
+}
+
+func TestReadinessDuringShutdown(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Register("upstream", true, func(ctx context.Context) error { return nil })
+	checker.SetShuttingDown()
+
+	w := httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code 503 while shutting down but got %d", w.Code)
+	}
+}
@@ -0,0 +1,72 @@
+// Package httpwriter provides the response writer wrapper shared by the HTTP
+// middleware (metrics, access log and tracing)
+package httpwriter
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// StatusWriter captures the status code and the number of bytes written, while
+// preserving http.Flusher and http.Hijacker so that middleware stacked on top of
+// each other keep streaming and connection upgrades working
+type StatusWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	bytesWritten int64
+	wroteHeader  bool
+}
+
+// New wraps w; the status code is 200 OK until the handler sets another
+func New(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+// StatusCode returns the status code of the response
+func (sw *StatusWriter) StatusCode() int {
+	return sw.statusCode
+}
+
+// BytesWritten returns the number of body bytes written
+func (sw *StatusWriter) BytesWritten() int64 {
+	return sw.bytesWritten
+}
+
+// WriteHeader captures the status code before calling the wrapped ResponseWriter
+func (sw *StatusWriter) WriteHeader(statusCode int) {
+	if !sw.wroteHeader {
+		sw.statusCode = statusCode
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write counts the bytes written to the wrapped ResponseWriter
+func (sw *StatusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytesWritten += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher so streaming responses keep working
+func (sw *StatusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the wrapped ResponseWriter supports it
+func (sw *StatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := sw.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("underlying ResponseWriter does not implement http.Hijacker")
+}
+
+// Unwrap returns the wrapped ResponseWriter for use by http.ResponseController
+func (sw *StatusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
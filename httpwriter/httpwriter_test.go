@@ -0,0 +1,52 @@
+package httpwriter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusWriterCountsBytes(t *testing.T) {
+	sw := New(httptest.NewRecorder())
+
+	sw.WriteHeader(http.StatusCreated)
+	sw.WriteHeader(http.StatusInternalServerError) // Superfluous calls must not change the status
+	sw.Write([]byte("hello"))
+	sw.Write([]byte(" world"))
+
+	if sw.StatusCode() != http.StatusCreated {
+		t.Errorf("expected status 201 but got %d", sw.StatusCode())
+	}
+	if sw.BytesWritten() != 11 {
+		t.Errorf("expected 11 bytes written but got %d", sw.BytesWritten())
+	}
+}
+
+func TestStatusWriterHijack(t *testing.T) {
+	// httptest.ResponseRecorder cannot be hijacked; the error must say so rather than panic
+	if _, _, err := New(httptest.NewRecorder()).Hijack(); err == nil {
+		t.Errorf("expected an error hijacking a ResponseWriter without http.Hijacker")
+	}
+
+	// Nested wrappers delegate to the connection
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := New(New(w)).Hijack()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n")
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected the hijacked response 204 but got %d", resp.StatusCode)
+	}
+}
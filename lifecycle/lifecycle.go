@@ -0,0 +1,210 @@
+// Package lifecycle runs the application's HTTP servers and shuts them down
+// gracefully on SIGINT/SIGTERM
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+)
+
+// Hook stops a background component, giving up when ctx is done
+type Hook func(ctx context.Context) error
+
+// namedHook is a shutdown hook along with the component name used in logs
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+// server is an HTTP server managed by the App
+type server struct {
+	name     string
+	server   *http.Server
+	listener net.Listener
+}
+
+// App owns the application's servers and background components. Run serves until
+// a termination signal arrives, then shuts everything down in a fixed order:
+//
+//  1. drain callbacks run (e.g. readiness starts failing)
+//  2. the servers keep serving for the shutdown delay, so load balancers stop routing traffic
+//  3. the servers stop accepting connections and wait for in-flight requests
+//  4. shutdown hooks run in registration order
+//
+// Steps 3 and 4 share the shutdown grace period.
+type App struct {
+	config        models.ServerConfig
+	servers       []*server
+	drainHooks    []func()
+	shutdownHooks []namedHook
+}
+
+// New creates an App using the shutdown settings from config
+func New(config models.ServerConfig) *App {
+	return &App{config: config}
+}
+
+// NewServer creates an http.Server with the timeouts and header limit from config
+func NewServer(addr string, handler http.Handler, config models.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(config.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(config.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(config.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(config.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+}
+
+// AddServer registers an HTTP server to be started by Run
+func (a *App) AddServer(name string, srv *http.Server) {
+	a.servers = append(a.servers, &server{name: name, server: srv})
+}
+
+// OnDrain registers a callback run as soon as shutdown begins, before the servers
+// stop accepting requests
+func (a *App) OnDrain(fn func()) {
+	a.drainHooks = append(a.drainHooks, fn)
+}
+
+// OnShutdown registers a hook run after the servers have stopped. Hooks run in
+// registration order, so components should be registered before the ones they use
+// (e.g. the logger last).
+func (a *App) OnShutdown(name string, hook Hook) {
+	a.shutdownHooks = append(a.shutdownHooks, namedHook{name: name, hook: hook})
+}
+
+// Run starts every server and blocks until ctx is cancelled, SIGINT or SIGTERM is
+// received, or a server fails. It then shuts down and returns any errors encountered.
+func (a *App) Run(ctx context.Context) error {
+	if err := a.listen(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return a.serve(ctx)
+}
+
+// listen opens the listeners of all servers, so a port that is already in use
+// fails startup before anything is served
+func (a *App) listen() error {
+	for i, s := range a.servers {
+		listener, err := Listen(s.server.Addr)
+		if err != nil {
+			for _, started := range a.servers[:i] {
+				started.listener.Close()
+			}
+			return fmt.Errorf("failed to listen for %s server on %s: %v", s.name, s.server.Addr, err)
+		}
+		s.listener = listener
+		logger.Info("%s server listening on %s (TLS %v)", s.name, listener.Addr(), s.server.TLSConfig != nil)
+	}
+	return nil
+}
+
+// Listen opens a listener for addr, which is either a TCP address (":8080") or a
+// unix socket path prefixed with "unix:". A stale socket file left behind by a
+// previous process is removed, and the socket is only accessible to the owner and group.
+func Listen(addr string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(addr, "unix:")
+	if !isUnix {
+		return net.Listen("tcp", addr)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// IsUnixSocket reports whether addr names a unix socket rather than a TCP address
+func IsUnixSocket(addr string) bool {
+	return strings.HasPrefix(addr, "unix:")
+}
+
+// serve runs the servers until ctx is done or one of them fails, then shuts down
+func (a *App) serve(ctx context.Context) error {
+	serveErrors := make(chan error, len(a.servers))
+	for _, s := range a.servers {
+		go func(s *server) {
+			var err error
+			if s.server.TLSConfig != nil {
+				// Certificates come from TLSConfig; HTTP/2 is enabled automatically
+				err = s.server.ServeTLS(s.listener, "", "")
+			} else {
+				err = s.server.Serve(s.listener)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErrors <- fmt.Errorf("%s server failed: %v", s.name, err)
+			}
+		}(s)
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		logger.Info("Shutdown requested, draining for %ds (grace period %ds)", a.config.ShutdownDelay, a.config.ShutdownGracePeriod)
+	case serveErr = <-serveErrors:
+		logger.Error("%v, shutting down", serveErr)
+	}
+
+	return errors.Join(serveErr, a.shutdown(serveErr == nil))
+}
+
+// shutdown stops the servers and background components in order. The shutdown
+// delay is skipped when a server has already failed.
+func (a *App) shutdown(delay bool) error {
+	for _, drain := range a.drainHooks {
+		drain()
+	}
+
+	if delay && a.config.ShutdownDelay > 0 {
+		time.Sleep(time.Duration(a.config.ShutdownDelay) * time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.config.ShutdownGracePeriod)*time.Second)
+	defer cancel()
+
+	var errs []error
+	for _, s := range a.servers {
+		if err := s.server.Shutdown(ctx); err != nil {
+			// The grace period expired: cut off the remaining connections
+			logger.Warn("%s server did not drain in time, closing remaining connections: %v", s.name, err)
+			s.server.Close()
+			errs = append(errs, fmt.Errorf("%s server shutdown: %v", s.name, err))
+			continue
+		}
+		logger.Info("%s server stopped", s.name)
+	}
+
+	for _, h := range a.shutdownHooks {
+		if err := h.hook(ctx); err != nil {
+			logger.Warn("Failed to shut down %s: %v", h.name, err)
+			errs = append(errs, fmt.Errorf("%s shutdown: %v", h.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
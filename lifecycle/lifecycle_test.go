@@ -0,0 +1,115 @@
+package lifecycle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pulsegrade/test1/models"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	config := models.ServerConfig{ShutdownGracePeriod: 5}
+	app := New(config)
+	app.AddServer("test", NewServer("127.0.0.1:0", handler, config))
+
+	var order []string
+	app.OnDrain(func() { order = append(order, "drain") })
+	app.OnShutdown("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	app.OnShutdown("second", func(ctx context.Context) error {
+		order = append(order, "second")
+		return nil
+	})
+
+	if err := app.listen(); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	url := "http://" + app.servers[0].listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- app.serve(ctx) }()
+
+	// Start a request, then request shutdown while it is in flight
+	response := make(chan string)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+	cancel()
+
+	// The in-flight request must still complete
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if body := <-response; body != "done" {
+		t.Errorf("expected in-flight request to complete but got %q", body)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("expected clean shutdown but got %v", err)
+	}
+	if got := strings.Join(order, ","); got != "drain,first,second" {
+		t.Errorf("expected shutdown order drain,first,second but got %s", got)
+	}
+}
+
+func TestListenFailure(t *testing.T) {
+	config := models.ServerConfig{}
+	first := New(config)
+	first.AddServer("first", NewServer("127.0.0.1:0", http.NotFoundHandler(), config))
+	if err := first.listen(); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer first.servers[0].listener.Close()
+
+	second := New(config)
+	second.AddServer("second", NewServer(first.servers[0].listener.Addr().String(), http.NotFoundHandler(), config))
+	if err := second.Run(context.Background()); err == nil {
+		t.Errorf("expected an error when the port is already in use")
+	}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+
+	// A stale socket file from a previous run must not prevent startup
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("failed to create stale socket file: %v", err)
+	}
+
+	listener, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("failed to listen on unix socket: %v", err)
+	}
+	defer listener.Close()
+
+	if listener.Addr().Network() != "unix" {
+		t.Errorf("expected a unix listener but got %s", listener.Addr().Network())
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("expected socket permissions 0660 but got %v (%v)", info.Mode().Perm(), err)
+	}
+}
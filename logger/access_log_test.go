@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogClientIP(t *testing.T) {
+	al := newAccessLogger(AccessLogConfig{
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{
+			name:       "Direct client without proxy",
+			remoteAddr: "203.0.113.7:5555",
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:         "Untrusted peer cannot spoof X-Forwarded-For",
+			remoteAddr:   "203.0.113.7:5555",
+			forwardedFor: "1.2.3.4",
+			expectedIP:   "203.0.113.7",
+		},
+		{
+			name:         "Trusted proxy forwards client address",
+			remoteAddr:   "10.1.2.3:5555",
+			forwardedFor: "198.51.100.20",
+			expectedIP:   "198.51.100.20",
+		},
+		{
+			name:         "Chain of trusted proxies is skipped",
+			remoteAddr:   "10.1.2.3:5555",
+			forwardedFor: "1.2.3.4, 198.51.100.20, 192.168.1.1",
+			expectedIP:   "198.51.100.20",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/income-salary", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+
+			if ip := al.clientIP(req); ip != tc.expectedIP {
+				t.Errorf("expected client IP %s but got %s", tc.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/income-salary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"salary":50000}`))
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("JSON format", func(t *testing.T) {
+		var buf bytes.Buffer
+		handler := RequestIDMiddleware(AccessLogMiddleware(mux, AccessLogConfig{
+			Enabled:      true,
+			Format:       AccessLogJSON,
+			Output:       &buf,
+			SampleRate:   1,
+			ExcludePaths: []string{"/metrics"},
+		}))
+
+		req := httptest.NewRequest("GET", "/income-salary?salary=50000", nil)
+		req.Header.Set(RequestIDHeader, "test-request-id")
+		req.Header.Set("User-Agent", "test-agent")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("expected 1 access log line but got %d: %q", len(lines), buf.String())
+		}
+
+		var entry accessLogEntry
+		if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+			t.Fatalf("failed to decode access log line: %v", err)
+		}
+
+		if entry.Route != "/income-salary" {
+			t.Errorf("expected route /income-salary but got %s", entry.Route)
+		}
+		if entry.Status != http.StatusOK {
+			t.Errorf("expected status 200 but got %d", entry.Status)
+		}
+		if entry.Bytes != int64(len(`{"salary":50000}`)) {
+			t.Errorf("expected %d bytes but got %d", len(`{"salary":50000}`), entry.Bytes)
+		}
+		if entry.RequestID != "test-request-id" {
+			t.Errorf("expected request ID test-request-id but got %s", entry.RequestID)
+		}
+		if entry.UserAgent != "test-agent" {
+			t.Errorf("expected user agent test-agent but got %s", entry.UserAgent)
+		}
+	})
+
+	t.Run("Combined format", func(t *testing.T) {
+		var buf bytes.Buffer
+		handler := AccessLogMiddleware(mux, AccessLogConfig{
+			Enabled:    true,
+			Format:     AccessLogCombined,
+			Output:     &buf,
+			SampleRate: 1,
+		})
+
+		req := httptest.NewRequest("GET", "/unknown", nil)
+		req.Header.Set("User-Agent", "test-agent")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		line := buf.String()
+		if !strings.Contains(line, `"GET /unknown HTTP/1.1" 404`) {
+			t.Errorf("expected request line and status in %q", line)
+		}
+		if !strings.Contains(line, `"test-agent"`) {
+			t.Errorf("expected user agent in %q", line)
+		}
+	})
+
+	t.Run("Sampling keeps server errors", func(t *testing.T) {
+		var buf bytes.Buffer
+		failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		handler := AccessLogMiddleware(failing, AccessLogConfig{
+			Enabled:    true,
+			Format:     AccessLogCommon,
+			Output:     &buf,
+			SampleRate: 0,
+		})
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/income-salary", nil))
+
+		if !strings.Contains(buf.String(), " 500 ") {
+			t.Errorf("expected server error to be logged despite sampling, got %q", buf.String())
+		}
+	})
+}
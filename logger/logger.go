@@ -32,14 +32,16 @@ type Logger struct {
 	enabled bool
 	level   LogLevel
 	logger  *log.Logger
+	sampler *sampler // Optional per-template rate limiting
 	mu      sync.Mutex
 }
 
 // Config holds configuration for the logger
 type Config struct {
-	Enabled bool
-	Level   LogLevel
-	Output  io.Writer
+	Enabled  bool
+	Level    LogLevel
+	Output   io.Writer
+	Sampling SamplingConfig
 }
 
 // init initializes the default logger
@@ -58,11 +60,18 @@ func New(config Config) *Logger {
 		output = os.Stdout
 	}
 
-	return &Logger{
+	l := &Logger{
 		enabled: config.Enabled,
 		level:   config.Level,
 		logger:  log.New(output, "", log.LstdFlags),
 	}
+
+	if config.Sampling.Enabled {
+		l.sampler = newSampler(config.Sampling)
+		l.sampler.start(l)
+	}
+
+	return l
 }
 
 // SetDefault sets the default logger instance
@@ -83,6 +92,22 @@ func Configure(config Config) {
 	defaultLogger.enabled = config.Enabled
 	defaultLogger.level = config.Level
 	defaultLogger.logger = log.New(output, "", log.LstdFlags)
+
+	// Replace any previous sampler so its summary goroutine does not leak
+	if defaultLogger.sampler != nil {
+		defaultLogger.sampler.close()
+		defaultLogger.sampler = nil
+	}
+	if config.Sampling.Enabled {
+		defaultLogger.sampler = newSampler(config.Sampling)
+		defaultLogger.sampler.start(defaultLogger)
+	}
+}
+
+// Close stops background work of the default logger, writing any pending
+// suppression summaries
+func Close() {
+	defaultLogger.Close()
 }
 
 // // SetEnabled enables or disables logging for the default logger
@@ -101,30 +126,22 @@ func Configure(config Config) {
 
 // Debug logs a debug message if the logger is enabled and level is appropriate
 func Debug(format string, v ...interface{}) {
-	if defaultLogger.enabled && defaultLogger.level >= LevelDebug {
-		defaultLogger.logger.Printf("[DEBUG] "+format, v...)
-	}
+	defaultLogger.logf(LevelDebug, format, v...)
 }
 
 // Info logs an info message if the logger is enabled and level is appropriate
 func Info(format string, v ...interface{}) {
-	if defaultLogger.enabled && defaultLogger.level >= LevelInfo {
-		defaultLogger.logger.Printf("[INFO] "+format, v...)
-	}
+	defaultLogger.logf(LevelInfo, format, v...)
 }
 
 // Warn logs a warning message if the logger is enabled and level is appropriate
 func Warn(format string, v ...interface{}) {
-	if defaultLogger.enabled && defaultLogger.level >= LevelWarn {
-		defaultLogger.logger.Printf("[WARN] "+format, v...)
-	}
+	defaultLogger.logf(LevelWarn, format, v...)
 }
 
 // Error logs an error message if the logger is enabled and level is appropriate
 func Error(format string, v ...interface{}) {
-	if defaultLogger.enabled && defaultLogger.level >= LevelError {
-		defaultLogger.logger.Printf("[ERROR] "+format, v...)
-	}
+	defaultLogger.logf(LevelError, format, v...)
 }
 
 // Fatal logs a fatal error message and exits
@@ -140,30 +157,22 @@ func Fatal(format string, v ...interface{}) {
 
 // Debug logs a debug message using the logger instance
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.enabled && l.level >= LevelDebug {
-		l.logger.Printf("[DEBUG] "+format, v...)
-	}
+	l.logf(LevelDebug, format, v...)
 }
 
 // Info logs an info message using the logger instance
 func (l *Logger) Info(format string, v ...interface{}) {
-	if l.enabled && l.level >= LevelInfo {
-		l.logger.Printf("[INFO] "+format, v...)
-	}
+	l.logf(LevelInfo, format, v...)
 }
 
 // Warn logs a warning message using the logger instance
 func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.enabled && l.level >= LevelWarn {
-		l.logger.Printf("[WARN] "+format, v...)
-	}
+	l.logf(LevelWarn, format, v...)
 }
 
 // Error logs an error message using the logger instance
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.enabled && l.level >= LevelError {
-		l.logger.Printf("[ERROR] "+format, v...)
-	}
+	l.logf(LevelError, format, v...)
 }
 
 // Fatal logs a fatal error message and exits
@@ -175,6 +184,29 @@ func (l *Logger) Fatal(format string, v ...interface{}) {
 	os.Exit(1)
 }
 
+// Close stops the sampler's summary goroutine, writing any pending summaries
+func (l *Logger) Close() {
+	if l.sampler != nil {
+		l.sampler.close()
+	}
+}
+
+// logf writes a message if the level is enabled and the sampler allows it
+func (l *Logger) logf(level LogLevel, format string, v ...interface{}) {
+	if !l.enabled || l.level < level {
+		return
+	}
+	if l.sampler != nil && !l.sampler.allow(level, format) {
+		return
+	}
+	l.write(level, format, v...)
+}
+
+// write formats and writes a message with its level prefix, bypassing sampling
+func (l *Logger) write(level LogLevel, format string, v ...interface{}) {
+	l.logger.Printf("["+level.String()+"] "+format, v...)
+}
+
 // String returns a string representation of the log level
 func (l LogLevel) String() string {
 	switch l {
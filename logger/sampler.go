@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingConfig controls per-message-template rate limiting. Within each
+// interval the first N messages of a template are logged, after that only
+// every Mth message is logged and the rest are counted as suppressed.
+type SamplingConfig struct {
+	Enabled    bool
+	Interval   time.Duration // Length of each sampling window
+	First      int           // Messages per template logged in full in each window
+	Thereafter int           // After First, log every Nth message (0 drops all of them)
+}
+
+// templateCounter tracks how often a single message template was seen
+type templateCounter struct {
+	level       LogLevel
+	windowStart time.Time
+	count       int // Messages seen in the current window
+	suppressed  int // Messages dropped since the last summary
+}
+
+// sampler decides which log lines are written and reports suppressed ones
+type sampler struct {
+	config   SamplingConfig
+	mu       sync.Mutex
+	counters map[string]*templateCounter
+	now      func() time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// DropHook is called for every log line suppressed by sampling
+type DropHook func(level LogLevel)
+
+// dropHook is invoked for every suppressed line; set via SetDropHook. It is read
+// by request goroutines and the sampler while it may be replaced, so it is atomic.
+var dropHook atomic.Pointer[DropHook]
+
+// SetDropHook registers a function called for every log line dropped by sampling,
+// typically used to increment a metric. Passing nil removes the hook.
+func SetDropHook(hook DropHook) {
+	if hook == nil {
+		dropHook.Store(nil)
+		return
+	}
+	dropHook.Store(&hook)
+}
+
+// newSampler creates a sampler for the given configuration
+func newSampler(config SamplingConfig) *sampler {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+
+	return &sampler{
+		config:   config,
+		counters: make(map[string]*templateCounter),
+		now:      time.Now,
+	}
+}
+
+// allow reports whether a message with the given template should be written
+func (s *sampler) allow(level LogLevel, format string) bool {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := level.String() + "|" + format
+	counter, ok := s.counters[key]
+	if !ok {
+		counter = &templateCounter{level: level, windowStart: now}
+		s.counters[key] = counter
+	}
+
+	// Start a new window once the interval has elapsed
+	if now.Sub(counter.windowStart) >= s.config.Interval {
+		counter.windowStart = now
+		counter.count = 0
+	}
+
+	counter.count++
+	if counter.count <= s.config.First {
+		return true
+	}
+
+	if s.config.Thereafter > 0 && (counter.count-s.config.First)%s.config.Thereafter == 0 {
+		return true
+	}
+
+	counter.suppressed++
+	if hook := dropHook.Load(); hook != nil {
+		(*hook)(level)
+	}
+	return false
+}
+
+// flush writes a summary line for every template with suppressed messages and
+// forgets templates that have been idle for a full interval
+func (s *sampler) flush(l *Logger) {
+	now := s.now()
+
+	type summary struct {
+		level      LogLevel
+		format     string
+		suppressed int
+	}
+	var summaries []summary
+
+	s.mu.Lock()
+	for key, counter := range s.counters {
+		if counter.suppressed > 0 {
+			summaries = append(summaries, summary{
+				level:      counter.level,
+				format:     key[len(counter.level.String())+1:],
+				suppressed: counter.suppressed,
+			})
+			counter.suppressed = 0
+		} else if now.Sub(counter.windowStart) >= 2*s.config.Interval {
+			delete(s.counters, key)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sm := range summaries {
+		l.write(sm.level, "Suppressed %d similar messages in the last %v: %q",
+			sm.suppressed, s.config.Interval, sm.format)
+	}
+}
+
+// start launches the goroutine that periodically writes suppression summaries
+func (s *sampler) start(l *Logger) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.flush(l)
+			case <-s.stop:
+				s.flush(l)
+				return
+			}
+		}
+	}()
+}
+
+// close stops the summary goroutine after writing a final summary
+func (s *sampler) close() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newSampler(SamplingConfig{Enabled: true, Interval: time.Second, First: 3, Thereafter: 5})
+	s.now = func() time.Time { return now }
+
+	dropped := 0
+	SetDropHook(func(level LogLevel) { dropped++ })
+	defer SetDropHook(nil)
+
+	allowed := 0
+	for i := 0; i < 23; i++ {
+		if s.allow(LevelError, "===> Error forwarding request: %v") {
+			allowed++
+		}
+	}
+
+	// First 3 pass, then every 5th of the remaining 20 (4 more)
+	if allowed != 7 {
+		t.Errorf("expected 7 messages allowed but got %d", allowed)
+	}
+	if dropped != 16 {
+		t.Errorf("expected 16 messages dropped but got %d", dropped)
+	}
+
+	// A different template has its own budget
+	if !s.allow(LevelError, "===> Error reading response body: %v") {
+		t.Errorf("expected a different template to be allowed")
+	}
+
+	// A new window resets the budget
+	now = now.Add(time.Second)
+	if !s.allow(LevelError, "===> Error forwarding request: %v") {
+		t.Errorf("expected message to be allowed in a new window")
+	}
+}
+
+func TestSamplerSummary(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Enabled: true, Level: LevelInfo, Output: &buf})
+	l.sampler = newSampler(SamplingConfig{Enabled: true, Interval: time.Minute, First: 1})
+
+	for i := 0; i < 5; i++ {
+		l.Info("Circuit breaker '%s' changed state", "tax-service")
+	}
+	l.sampler.flush(l)
+
+	output := buf.String()
+	if strings.Count(output, "changed state") != 2 {
+		t.Errorf("expected one logged message and one summary, got %q", output)
+	}
+	if !strings.Contains(output, "[INFO] Suppressed 4 similar messages") {
+		t.Errorf("expected suppression summary in %q", output)
+	}
+}
+
+func TestSetDropHookConcurrent(t *testing.T) {
+	s := newSampler(SamplingConfig{Enabled: true, Interval: time.Hour})
+	defer SetDropHook(nil)
+
+	var dropped atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.allow(LevelInfo, "request handled")
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		SetDropHook(func(level LogLevel) { dropped.Add(1) })
+	}
+	wg.Wait()
+
+	SetDropHook(func(level LogLevel) { dropped.Add(1) })
+	before := dropped.Load()
+	s.allow(LevelInfo, "request handled")
+	if dropped.Load() != before+1 {
+		t.Errorf("expected the last hook registered to see the dropped line")
+	}
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/version"
+)
+
+// RecordBuildInfo publishes the running build and the application start time
+func (r *Registry) RecordBuildInfo(info version.Info, startTime time.Time, environment string) {
+	r.BuildInfo.WithLabelValues(info.Version, info.Commit, info.GoVersion, environment).Set(1)
+	r.StartTime.WithLabelValues(environment).Set(float64(startTime.UnixNano()) / 1e9)
+}
+
+// RecordConfig publishes the configuration settings that change application behavior
+func (r *Registry) RecordConfig(config models.Config) {
+	r.ConfigInfo.WithLabelValues(
+		strconv.FormatBool(config.CircuitBreakerEnabled),
+		strconv.FormatBool(config.IncludeTaxYear),
+		config.Environment,
+	).Set(1)
+
+	r.CircuitBreakerConfig.WithLabelValues("request_threshold", config.Environment).Set(float64(config.CircuitBreaker.RequestThreshold))
+	r.CircuitBreakerConfig.WithLabelValues("failure_ratio", config.Environment).Set(config.CircuitBreaker.FailureRatio)
+	r.CircuitBreakerConfig.WithLabelValues("timeout_seconds", config.Environment).Set(float64(config.CircuitBreaker.Timeout))
+	r.CircuitBreakerConfig.WithLabelValues("max_half_open_requests", config.Environment).Set(float64(config.CircuitBreaker.MaxHalfOpenReqs))
+}
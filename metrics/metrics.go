@@ -1,72 +1,380 @@
 package metrics
 
 import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var (
+// UpstreamDurationBuckets cover upstream latencies up to the 35 second client timeout
+var UpstreamDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 35}
+
+// EffectiveRateBuckets cover effective tax rates from 0% to 60% in 5% steps
+var EffectiveRateBuckets = prometheus.LinearBuckets(0, 0.05, 13)
+
+// SalaryBandEdges are the upper bounds of the salary bands used to label calculations.
+// Salaries are only ever exposed as a band so individual values cannot be recovered.
+var SalaryBandEdges = []float64{10000, 25000, 50000, 75000, 100000, 150000, 200000, 300000, 500000, 1000000}
+
+// DefaultSizeBuckets are the default request/response size buckets in bytes (100B to ~1.6MB)
+var DefaultSizeBuckets = prometheus.ExponentialBuckets(100, 4, 8)
+
+// Registry holds the application's Prometheus collectors. Each Registry owns its
+// collectors, so tests and multiple TaxCalculator instances in one process do not
+// share state.
+type Registry struct {
 	// HttpRequestsTotal counts the number of HTTP requests processed
-	HttpRequestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "taxapp_http_requests_total",
-			Help: "The total number of HTTP requests",
-		},
-		[]string{"endpoint", "method", "status", "environment"},
-	)
+	HttpRequestsTotal *prometheus.CounterVec
 
 	// HttpRequestDuration tracks the duration of HTTP requests
-	HttpRequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "taxapp_http_request_duration_seconds",
-			Help:    "Duration of HTTP requests in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"endpoint", "method", "environment"},
-	)
+	HttpRequestDuration *prometheus.HistogramVec
+
+	// HttpRequestSize tracks the size of HTTP request bodies
+	HttpRequestSize *prometheus.HistogramVec
+
+	// HttpResponseSize tracks the size of HTTP response bodies
+	HttpResponseSize *prometheus.HistogramVec
+
+	// HttpRequestsInFlight tracks the number of HTTP requests currently being served
+	HttpRequestsInFlight *prometheus.GaugeVec
 
 	// TaxCalculationTotal counts the number of tax calculations performed
-	TaxCalculationTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "taxapp_tax_calculations_total",
-			Help: "The total number of tax calculations performed",
-		},
-		[]string{"environment"},
-	)
+	TaxCalculationTotal *prometheus.CounterVec
+
+	// TaxCalculationsByYear counts tax calculations per tax year and input channel
+	TaxCalculationsByYear *prometheus.CounterVec
+
+	// TaxCalculationsBySalaryBand counts tax calculations per salary band. A counter per band is
+	// used instead of a histogram so that no sum of salaries is exported.
+	TaxCalculationsBySalaryBand *prometheus.CounterVec
+
+	// TaxEffectiveRate tracks the distribution of computed effective tax rates
+	TaxEffectiveRate *prometheus.HistogramVec
+
+	// TaxBracketFetchTime records when the brackets of each tax year were last fetched
+	// successfully, in seconds since the Unix epoch; time() minus it is the age of the
+	// bracket data in use
+	TaxBracketFetchTime *prometheus.GaugeVec
 
 	// TaxServiceErrors counts the number of errors from the tax service
-	TaxServiceErrors = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "taxapp_tax_service_errors_total",
-			Help: "The total number of errors from the tax service",
-		},
-		[]string{"environment"},
-	)
+	TaxServiceErrors *prometheus.CounterVec
+
+	// UpstreamRequestsTotal counts calls to the upstream tax calculator by outcome
+	UpstreamRequestsTotal *prometheus.CounterVec
+
+	// UpstreamRequestDuration tracks the latency of calls to the upstream tax calculator
+	UpstreamRequestDuration *prometheus.HistogramVec
+
+	// UpstreamConnectionPhaseDuration tracks connection-level timings of upstream calls (DNS, connect, TLS, time to first byte)
+	UpstreamConnectionPhaseDuration *prometheus.HistogramVec
 
 	// CircuitBreakerState tracks the current state of the circuit breaker (1=closed, 2=half-open, 3=open)
-	CircuitBreakerState = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "taxapp_circuit_breaker_state",
-			Help: "Current state of the circuit breaker: 1=closed, 2=half-open, 3=open",
-		},
-		[]string{"name", "environment"},
-	)
+	CircuitBreakerState *prometheus.GaugeVec
 
 	// CircuitBreakerRejected counts requests rejected due to open circuit
-	CircuitBreakerRejected = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "taxapp_circuit_breaker_rejected_total",
-			Help: "Number of requests rejected due to open circuit",
-		},
-		[]string{"name", "environment"},
-	)
+	CircuitBreakerRejected *prometheus.CounterVec
 
 	// CircuitBreakerRequests counts requests going through circuit breaker
-	CircuitBreakerRequests = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "taxapp_circuit_breaker_requests_total",
-			Help: "Number of requests going through circuit breaker",
-		},
-		[]string{"name", "success", "environment"},
-	)
+	CircuitBreakerRequests *prometheus.CounterVec
+
+	// LogLinesDropped counts log lines suppressed by log sampling
+	LogLinesDropped *prometheus.CounterVec
+
+	// BuildInfo is always 1 and labels the running build, so dashboards can tell versions apart
+	BuildInfo *prometheus.GaugeVec
+
+	// StartTime records when the application started, in seconds since the Unix epoch
+	StartTime *prometheus.GaugeVec
+
+	// ConfigInfo is always 1 and labels the feature flags the application was started with
+	ConfigInfo *prometheus.GaugeVec
+
+	// CircuitBreakerConfig exposes the configured circuit breaker thresholds
+	CircuitBreakerConfig *prometheus.GaugeVec
+
+	registry *prometheus.Registry // Registry the collectors are exported from (nil for no-op)
+}
+
+// Options configures the collectors created for a Registry
+type Options struct {
+	DurationBuckets []float64 // HTTP request duration buckets in seconds (empty uses prometheus.DefBuckets)
+	SizeBuckets     []float64 // HTTP request/response size buckets in bytes (empty uses DefaultSizeBuckets)
+}
+
+var (
+	// defaultRegistry is the lazily created process-wide registry returned by Default
+	defaultRegistry *Registry
+	defaultOnce     sync.Once
 )
+
+// NewRegistry creates a Registry whose collectors, along with the Go runtime and
+// process collectors, are registered on a new Prometheus registry
+func NewRegistry(opts Options) *Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	r := newRegistry(promauto.With(registry), opts)
+	r.registry = registry
+	return r
+}
+
+// Default returns the process-wide Registry with default options. It is used by
+// constructors that are not given a Registry explicitly.
+func Default() *Registry {
+	defaultOnce.Do(func() {
+		defaultRegistry = NewRegistry(Options{})
+	})
+	return defaultRegistry
+}
+
+// NewNoop creates a Registry whose collectors are not registered anywhere, so
+// everything recorded through it is discarded
+func NewNoop() *Registry {
+	return newRegistry(promauto.With(nil), Options{})
+}
+
+// newRegistry creates all application collectors using the given factory
+func newRegistry(factory promauto.Factory, opts Options) *Registry {
+	durationBuckets := opts.DurationBuckets
+	if len(durationBuckets) == 0 {
+		durationBuckets = prometheus.DefBuckets
+	}
+
+	sizeBuckets := opts.SizeBuckets
+	if len(sizeBuckets) == 0 {
+		sizeBuckets = DefaultSizeBuckets
+	}
+
+	return &Registry{
+		HttpRequestsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_http_requests_total",
+				Help: "The total number of HTTP requests, labeled by route pattern",
+			},
+			[]string{"endpoint", "method", "status", "environment"},
+		),
+		HttpRequestDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "taxapp_http_request_duration_seconds",
+				Help:    "Duration of HTTP requests in seconds",
+				Buckets: durationBuckets,
+			},
+			[]string{"endpoint", "method", "environment"},
+		),
+		HttpRequestSize: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "taxapp_http_request_size_bytes",
+				Help:    "Size of HTTP request bodies in bytes",
+				Buckets: sizeBuckets,
+			},
+			[]string{"endpoint", "method", "environment"},
+		),
+		HttpResponseSize: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "taxapp_http_response_size_bytes",
+				Help:    "Size of HTTP response bodies in bytes",
+				Buckets: sizeBuckets,
+			},
+			[]string{"endpoint", "method", "environment"},
+		),
+		HttpRequestsInFlight: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "taxapp_http_requests_in_flight",
+				Help: "Number of HTTP requests currently being served",
+			},
+			[]string{"environment"},
+		),
+		TaxCalculationTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_tax_calculations_total",
+				Help: "The total number of tax calculations performed",
+			},
+			[]string{"environment"},
+		),
+		TaxCalculationsByYear: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_tax_calculations_by_year_total",
+				Help: "The total number of tax calculations by tax year and input channel (query, form, json, batch)",
+			},
+			[]string{"tax_year", "channel", "environment"},
+		),
+		TaxCalculationsBySalaryBand: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_tax_calculations_by_salary_band_total",
+				Help: "The total number of tax calculations by salary band",
+			},
+			[]string{"band", "environment"},
+		),
+		TaxEffectiveRate: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "taxapp_tax_effective_rate",
+				Help:    "Distribution of computed effective tax rates (0.0-1.0)",
+				Buckets: EffectiveRateBuckets,
+			},
+			[]string{"tax_year", "environment"},
+		),
+		TaxBracketFetchTime: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "taxapp_tax_bracket_fetch_time_seconds",
+				Help: "Time of the last successful tax bracket fetch by tax year, in seconds since the Unix epoch",
+			},
+			[]string{"tax_year", "environment"},
+		),
+		TaxServiceErrors: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_tax_service_errors_total",
+				Help: "The total number of errors from the tax service",
+			},
+			[]string{"environment"},
+		),
+		UpstreamRequestsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_upstream_requests_total",
+				Help: "Number of calls to the upstream tax calculator by tax year, HTTP status class and error kind",
+			},
+			[]string{"tax_year", "status_class", "error_kind", "environment"},
+		),
+		UpstreamRequestDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "taxapp_upstream_request_duration_seconds",
+				Help:    "Duration of calls to the upstream tax calculator in seconds",
+				Buckets: UpstreamDurationBuckets,
+			},
+			[]string{"tax_year", "status_class", "environment"},
+		),
+		UpstreamConnectionPhaseDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "taxapp_upstream_connection_phase_seconds",
+				Help:    "Duration of connection phases of calls to the upstream tax calculator: dns, connect, tls, ttfb",
+				Buckets: UpstreamDurationBuckets,
+			},
+			[]string{"phase", "environment"},
+		),
+		CircuitBreakerState: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "taxapp_circuit_breaker_state",
+				Help: "Current state of the circuit breaker: 1=closed, 2=half-open, 3=open",
+			},
+			[]string{"name", "environment"},
+		),
+		CircuitBreakerRejected: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_circuit_breaker_rejected_total",
+				Help: "Number of requests rejected due to open circuit",
+			},
+			[]string{"name", "environment"},
+		),
+		CircuitBreakerRequests: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_circuit_breaker_requests_total",
+				Help: "Number of requests going through circuit breaker",
+			},
+			[]string{"name", "success", "environment"},
+		),
+		LogLinesDropped: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "taxapp_log_lines_dropped_total",
+				Help: "Number of log lines suppressed by log sampling",
+			},
+			[]string{"level", "environment"},
+		),
+		BuildInfo: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "taxapp_build_info",
+				Help: "A metric with a constant '1' value labeled by version, commit and Go version of the running build",
+			},
+			[]string{"version", "commit", "go_version", "environment"},
+		),
+		StartTime: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "taxapp_start_time_seconds",
+				Help: "Start time of the application since the Unix epoch in seconds",
+			},
+			[]string{"environment"},
+		),
+		ConfigInfo: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "taxapp_config_info",
+				Help: "A metric with a constant '1' value labeled by the feature flags the application was started with",
+			},
+			[]string{"circuit_breaker_enabled", "include_tax_year", "environment"},
+		),
+		CircuitBreakerConfig: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "taxapp_circuit_breaker_config",
+				Help: "Configured circuit breaker settings: request_threshold, failure_ratio, timeout_seconds, max_half_open_requests",
+			},
+			[]string{"setting", "environment"},
+		),
+	}
+}
+
+// Handler returns an HTTP handler exposing the registry's metrics in the Prometheus format
+func (r *Registry) Handler() http.Handler {
+	if r.registry == nil {
+		return promhttp.HandlerFor(prometheus.NewRegistry(), promhttp.HandlerOpts{})
+	}
+	// OpenMetrics is required for exemplars (trace IDs) to be exposed
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{Registry: r.registry, EnableOpenMetrics: true})
+}
+
+// Gatherer returns the Prometheus gatherer backing the registry (nil for no-op registries)
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	if r.registry == nil {
+		return nil
+	}
+	return r.registry
+}
+
+// ObserveWithTrace records value on observer, attaching the trace ID of the sampled
+// span in ctx as an exemplar so latency outliers can be linked to their traces
+func ObserveWithTrace(ctx context.Context, observer prometheus.Observer, value float64) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && spanContext.IsSampled() {
+		exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+		return
+	}
+	observer.Observe(value)
+}
+
+// TaxYearLabel returns the metric label for a tax year; 0 means the upstream default year
+func TaxYearLabel(taxYear int) string {
+	if taxYear <= 0 {
+		return "default"
+	}
+	return strconv.Itoa(taxYear)
+}
+
+// SalaryBand returns the label of the salary band containing salary, e.g. "50k-75k" or "1M+"
+func SalaryBand(salary float64) string {
+	lower := 0.0
+	for _, upper := range SalaryBandEdges {
+		if salary < upper {
+			return formatBandEdge(lower) + "-" + formatBandEdge(upper)
+		}
+		lower = upper
+	}
+	return formatBandEdge(lower) + "+"
+}
+
+// formatBandEdge formats a salary band edge compactly (25000 -> "25k", 1000000 -> "1M")
+func formatBandEdge(value float64) string {
+	switch {
+	case value >= 1000000:
+		return strconv.FormatFloat(value/1000000, 'f', -1, 64) + "M"
+	case value >= 1000:
+		return strconv.FormatFloat(value/1000, 'f', -1, 64) + "k"
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pulsegrade/test1/models"
+	"pulsegrade/test1/version"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSalaryBand(t *testing.T) {
+	tests := []struct {
+		salary       float64
+		expectedBand string
+	}{
+		{salary: 0, expectedBand: "0-10k"},
+		{salary: 9999.99, expectedBand: "0-10k"},
+		{salary: 50000, expectedBand: "50k-75k"},
+		{salary: 75000, expectedBand: "75k-100k"},
+		{salary: 999999, expectedBand: "500k-1M"},
+		{salary: 5000000, expectedBand: "1M+"},
+	}
+
+	for _, tc := range tests {
+		if band := SalaryBand(tc.salary); band != tc.expectedBand {
+			t.Errorf("expected band %s for salary %.2f but got %s", tc.expectedBand, tc.salary, band)
+		}
+	}
+}
+
+func TestTaxYearLabel(t *testing.T) {
+	if label := TaxYearLabel(0); label != "default" {
+		t.Errorf("expected label default but got %s", label)
+	}
+	if label := TaxYearLabel(2022); label != "2022" {
+		t.Errorf("expected label 2022 but got %s", label)
+	}
+}
+
+func TestRegistryIsolation(t *testing.T) {
+	first := NewRegistry(Options{})
+	second := NewRegistry(Options{})
+
+	first.TaxCalculationTotal.WithLabelValues("test").Inc()
+	first.TaxCalculationTotal.WithLabelValues("test").Inc()
+
+	if count := first.CounterValue("taxapp_tax_calculations_total", prometheus.Labels{"environment": "test"}); count != 2 {
+		t.Errorf("expected 2 calculations in first registry but got %v", count)
+	}
+	if count := second.CounterValue("taxapp_tax_calculations_total", nil); count != 0 {
+		t.Errorf("expected 0 calculations in second registry but got %v", count)
+	}
+
+	// The handler exposes the registry's own collectors
+	w := httptest.NewRecorder()
+	first.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `taxapp_tax_calculations_total{environment="test"} 2`) {
+		t.Errorf("expected calculation counter in /metrics output")
+	}
+}
+
+func TestNoopRegistry(t *testing.T) {
+	noop := NewNoop()
+	noop.TaxServiceErrors.WithLabelValues("test").Inc()
+
+	if count := noop.CounterValue("taxapp_tax_service_errors_total", nil); count != 0 {
+		t.Errorf("expected no-op registry to report 0 but got %v", count)
+	}
+	if noop.Gatherer() != nil {
+		t.Errorf("expected no-op registry to have no gatherer")
+	}
+}
+
+func TestRecordBuildAndConfigInfo(t *testing.T) {
+	registry := NewRegistry(Options{})
+	startTime := time.Unix(1700000000, 0)
+
+	registry.RecordBuildInfo(version.Info{Version: "v1.2.3", Commit: "abc1234", GoVersion: "go1.23.2"}, startTime, "test")
+	registry.RecordConfig(models.Config{
+		Environment:           "test",
+		CircuitBreakerEnabled: true,
+		CircuitBreaker:        models.CircuitBreakerConfig{RequestThreshold: 10, FailureRatio: 0.5},
+	})
+
+	if value := registry.GaugeValue("taxapp_build_info", prometheus.Labels{"version": "v1.2.3", "commit": "abc1234"}); value != 1 {
+		t.Errorf("expected build info for v1.2.3 but got %v", value)
+	}
+	if value := registry.GaugeValue("taxapp_start_time_seconds", nil); value != 1700000000 {
+		t.Errorf("expected start time 1700000000 but got %v", value)
+	}
+	if value := registry.GaugeValue("taxapp_config_info", prometheus.Labels{"circuit_breaker_enabled": "true", "include_tax_year": "false"}); value != 1 {
+		t.Errorf("expected config info with circuit breaker enabled but got %v", value)
+	}
+	if value := registry.GaugeValue("taxapp_circuit_breaker_config", prometheus.Labels{"setting": "failure_ratio"}); value != 0.5 {
+		t.Errorf("expected failure ratio 0.5 but got %v", value)
+	}
+}
@@ -1,18 +1,34 @@
 package metrics
 
 import (
+	"io"
 	"net/http"
 	"strconv"
 	"time"
+
+	"pulsegrade/test1/httpwriter"
 )
 
-// MetricsMiddleware wraps an HTTP handler with metrics instrumentation
-func MetricsMiddleware(next http.Handler, environment string) http.Handler {
+// UnmatchedRoute is the endpoint label used for requests that matched no registered route
+const UnmatchedRoute = "unmatched"
+
+// MetricsMiddleware wraps an HTTP handler with metrics instrumentation.
+// Requests are labeled by the ServeMux route pattern that handled them rather than
+// the raw URL path, so unknown paths cannot create unbounded label cardinality.
+func MetricsMiddleware(next http.Handler, environment string, registry *Registry) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Create a response writer wrapper to capture the status code
-		rww := &responseWriterWrapper{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK, // Default to 200 OK
+		inFlight := registry.HttpRequestsInFlight.WithLabelValues(environment)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		// Create a response writer wrapper to capture the status code and bytes written
+		rww := httpwriter.New(w)
+
+		// Count request body bytes as the handler reads them
+		var body *countingReader
+		if r.Body != nil && r.Body != http.NoBody {
+			body = &countingReader{ReadCloser: r.Body}
+			r.Body = body
 		}
 
 		// Record start time
@@ -24,23 +40,37 @@ func MetricsMiddleware(next http.Handler, environment string) http.Handler {
 		// Record duration
 		duration := time.Since(startTime).Seconds()
 
-		// Extract path for the metrics label (could be improved with route pattern matching)
-		endpoint := r.URL.Path
+		// The ServeMux stores the matched pattern on the request it dispatched
+		endpoint := r.Pattern
+		if endpoint == "" {
+			endpoint = UnmatchedRoute
+		}
+
+		requestSize := r.ContentLength
+		if body != nil && body.bytesRead > requestSize {
+			requestSize = body.bytesRead
+		}
+		if requestSize < 0 {
+			requestSize = 0
+		}
 
 		// Record metrics
-		HttpRequestsTotal.WithLabelValues(endpoint, r.Method, strconv.Itoa(rww.statusCode), environment).Inc()
-		HttpRequestDuration.WithLabelValues(endpoint, r.Method, environment).Observe(duration)
+		registry.HttpRequestsTotal.WithLabelValues(endpoint, r.Method, strconv.Itoa(rww.StatusCode()), environment).Inc()
+		ObserveWithTrace(r.Context(), registry.HttpRequestDuration.WithLabelValues(endpoint, r.Method, environment), duration)
+		registry.HttpRequestSize.WithLabelValues(endpoint, r.Method, environment).Observe(float64(requestSize))
+		registry.HttpResponseSize.WithLabelValues(endpoint, r.Method, environment).Observe(float64(rww.BytesWritten()))
 	})
 }
 
-// responseWriterWrapper is a custom response writer that captures the status code
-type responseWriterWrapper struct {
-	http.ResponseWriter
-	statusCode int
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	bytesRead int64
 }
 
-// WriteHeader captures the status code before calling the wrapped ResponseWriter
-func (rww *responseWriterWrapper) WriteHeader(statusCode int) {
-	rww.statusCode = statusCode
-	rww.ResponseWriter.WriteHeader(statusCode)
+// Read counts the bytes read from the wrapped body
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.bytesRead += int64(n)
+	return n, err
 }
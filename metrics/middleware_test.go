@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/income-salary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"salary":50000}`))
+		if _, ok := w.(http.Flusher); !ok {
+			t.Errorf("expected wrapped ResponseWriter to implement http.Flusher")
+		}
+	})
+
+	environment := "test"
+	registry := NewRegistry(Options{})
+	handler := MetricsMiddleware(mux, environment, registry)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/income-salary?salary=50000", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-scanner-path-1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-scanner-path-2", nil))
+
+	t.Run("Labels by route pattern", func(t *testing.T) {
+		count := registry.CounterValue("taxapp_http_requests_total", prometheus.Labels{"endpoint": "/income-salary", "method": "GET", "status": "200"})
+		if count != 1 {
+			t.Errorf("expected 1 request for /income-salary but got %v", count)
+		}
+	})
+
+	t.Run("Unmatched paths share one label", func(t *testing.T) {
+		count := registry.CounterValue("taxapp_http_requests_total", prometheus.Labels{"endpoint": UnmatchedRoute, "status": "404"})
+		if count != 2 {
+			t.Errorf("expected 2 unmatched requests but got %v", count)
+		}
+	})
+
+	t.Run("Response sizes observed", func(t *testing.T) {
+		if count := registry.HistogramCount("taxapp_http_response_size_bytes", nil); count != 3 {
+			t.Errorf("expected 3 response size observations but got %d", count)
+		}
+	})
+
+	t.Run("In-flight gauge returns to zero", func(t *testing.T) {
+		if inFlight := registry.GaugeValue("taxapp_http_requests_in_flight", prometheus.Labels{"environment": environment}); inFlight != 0 {
+			t.Errorf("expected 0 in-flight requests but got %v", inFlight)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CounterValue returns the sum of all series of the named counter whose labels
+// include the given labels. It is intended for asserting metric values in tests.
+func (r *Registry) CounterValue(name string, labels prometheus.Labels) float64 {
+	var total float64
+	for _, metric := range r.series(name, labels) {
+		if metric.GetCounter() != nil {
+			total += metric.GetCounter().GetValue()
+		}
+	}
+	return total
+}
+
+// GaugeValue returns the sum of all series of the named gauge whose labels
+// include the given labels. It is intended for asserting metric values in tests.
+func (r *Registry) GaugeValue(name string, labels prometheus.Labels) float64 {
+	var total float64
+	for _, metric := range r.series(name, labels) {
+		if metric.GetGauge() != nil {
+			total += metric.GetGauge().GetValue()
+		}
+	}
+	return total
+}
+
+// HistogramCount returns the total number of observations of the named histogram
+// whose labels include the given labels. It is intended for asserting metric values in tests.
+func (r *Registry) HistogramCount(name string, labels prometheus.Labels) uint64 {
+	var total uint64
+	for _, metric := range r.series(name, labels) {
+		if metric.GetHistogram() != nil {
+			total += metric.GetHistogram().GetSampleCount()
+		}
+	}
+	return total
+}
+
+// series gathers the registry and returns the series of the named metric whose
+// labels include all of the given labels
+func (r *Registry) series(name string, labels prometheus.Labels) []*dto.Metric {
+	if r.registry == nil {
+		return nil
+	}
+
+	families, err := r.registry.Gather()
+	if err != nil {
+		return nil
+	}
+
+	var matched []*dto.Metric
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if hasLabels(metric, labels) {
+				matched = append(matched, metric)
+			}
+		}
+	}
+	return matched
+}
+
+// hasLabels reports whether the metric carries all of the given label values
+func hasLabels(metric *dto.Metric, labels prometheus.Labels) bool {
+	for name, value := range labels {
+		found := false
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == name && pair.GetValue() == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
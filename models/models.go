@@ -2,13 +2,94 @@ package models
 
 // Config holds application configuration
 type Config struct {
-	TaxCalcBaseURL        string
-	IncludeTaxYear        bool
-	Port                  string
-	Environment           string
-	CircuitBreakerEnabled bool
-	CircuitBreaker        CircuitBreakerConfig
-	Logging               LoggingConfig // Added logging configuration
+	TaxCalcBaseURL          string
+	TaxCalcTraceConnections bool // Record DNS/connect/TLS/TTFB timings of upstream calls
+	IncludeTaxYear          bool
+	IncludeJurisdiction     bool // Request brackets per jurisdiction code (a /jurisdiction/{code} URL segment)
+	Port                    string
+	Server                  ServerConfig
+	TLS                     TLSConfig
+	Admin                   AdminConfig
+	Batch                   BatchConfig
+	Validation              ValidationConfig
+	API                     APIConfig
+	Environment             string
+	CircuitBreakerEnabled   bool
+	CircuitBreaker          CircuitBreakerConfig
+	Logging                 LoggingConfig // Added logging configuration
+	AccessLog               AccessLogConfig
+	Metrics                 MetricsConfig
+	Tracing                 TracingConfig
+}
+
+// ServerConfig holds HTTP server timeouts and shutdown behavior
+type ServerConfig struct {
+	ReadTimeout         int // Seconds allowed to read an entire request, including the body
+	ReadHeaderTimeout   int // Seconds allowed to read request headers
+	WriteTimeout        int // Seconds allowed to write the response (must exceed the upstream timeout)
+	IdleTimeout         int // Seconds a keep-alive connection may stay idle
+	MaxHeaderBytes      int // Maximum size of request headers in bytes
+	MaxBodyBytes        int // Maximum size of a single calculation request body in bytes
+	ShutdownDelay       int // Seconds to keep serving while not ready, so load balancers stop routing traffic
+	ShutdownGracePeriod int // Seconds allowed for in-flight requests and background components to finish
+}
+
+// TLSConfig holds HTTPS and mutual TLS settings for the HTTP server
+type TLSConfig struct {
+	Enabled        bool     // Serve HTTPS (with HTTP/2) instead of plain HTTP
+	CertFile       string   // PEM certificate chain
+	KeyFile        string   // PEM private key
+	MinVersion     string   // Minimum TLS version (1.2 or 1.3)
+	CipherSuites   []string // TLS 1.2 cipher suite names (empty uses Go's secure defaults)
+	ClientCAFile   string   // PEM CA bundle used to verify client certificates (enables mTLS)
+	ClientAuth     string   // Client certificate mode (none, verify-if-given, require)
+	ReloadInterval int      // Seconds between checks of the certificate files for changes
+}
+
+// AdminConfig holds settings for the listener serving metrics, health checks and other operational endpoints
+type AdminConfig struct {
+	Address     string          // TCP address (e.g. ":9080") or unix socket (e.g. "unix:/run/taxapp/admin.sock")
+	TLS         bool            // Serve HTTPS using the tls settings (TCP addresses only)
+	EnablePprof bool            // Expose /debug/pprof profiling endpoints
+	Auth        AdminAuthConfig // Credentials required for admin endpoints other than health probes
+}
+
+// AdminAuthConfig holds credentials for the admin listener; when both are set either is accepted
+type AdminAuthConfig struct {
+	Token    string // Bearer token
+	Username string // Basic auth username
+	Password string // Basic auth password
+}
+
+// String redacts the credentials so the configuration can be logged safely
+func (c AdminAuthConfig) String() string {
+	redact := func(secret string) string {
+		if secret == "" {
+			return ""
+		}
+		return "[REDACTED]"
+	}
+	return "{Token:" + redact(c.Token) + " Username:" + c.Username + " Password:" + redact(c.Password) + "}"
+}
+
+// APIConfig holds settings for the public API routes
+type APIConfig struct {
+	LegacyDeprecation string // Date (YYYY-MM-DD) the unversioned routes were deprecated, sent in the Deprecation header
+	LegacySunset      string // Date (YYYY-MM-DD) the unversioned routes will be removed, sent in the Sunset header
+}
+
+// BatchConfig holds limits for the batch calculation endpoint
+type BatchConfig struct {
+	MaxItems     int   // Maximum items per request
+	MaxBodyBytes int64 // Maximum request body size in bytes
+}
+
+// ValidationConfig holds the bounds calculation inputs are checked against
+type ValidationConfig struct {
+	MinSalary  float64 // Lowest accepted salary (negative salaries are always rejected)
+	MaxSalary  float64 // Highest accepted salary (0 for no limit)
+	MinTaxYear int     // Earliest supported tax year (0 for no limit)
+	MaxTaxYear int     // Latest supported tax year (0 for the current year)
 }
 
 // CircuitBreakerConfig holds the circuit breaker configuration parameters
@@ -21,8 +102,43 @@ type CircuitBreakerConfig struct {
 
 // LoggingConfig holds configuration for application logging
 type LoggingConfig struct {
-	Enabled bool   // Whether logging is enabled
-	Level   string // Log level (NONE, ERROR, WARN, INFO, DEBUG)
+	Enabled  bool   // Whether logging is enabled
+	Level    string // Log level (NONE, ERROR, WARN, INFO, DEBUG)
+	Sampling LogSamplingConfig
+}
+
+// LogSamplingConfig holds per-message-template rate limiting settings
+type LogSamplingConfig struct {
+	Enabled    bool // Whether repeated messages are sampled
+	Interval   int  // Seconds per sampling window (also the summary period)
+	First      int  // Messages per template logged in full in each window
+	Thereafter int  // After First, log every Nth message (0 drops all of them)
+}
+
+// AccessLogConfig holds configuration for the HTTP access log
+type AccessLogConfig struct {
+	Enabled        bool     // Whether access logging is enabled
+	Format         string   // Line format (common, combined, json)
+	SampleRate     float64  // Fraction (0.0-1.0) of requests to log; server errors are always logged
+	ExcludePaths   []string // Paths that are never logged (e.g. /metrics)
+	TrustedProxies []string // IPs or CIDRs allowed to set X-Forwarded-For
+}
+
+// MetricsConfig holds configuration for Prometheus metrics
+type MetricsConfig struct {
+	DurationBuckets []float64 // HTTP request duration histogram buckets in seconds (empty uses defaults)
+	SizeBuckets     []float64 // HTTP request/response size histogram buckets in bytes (empty uses defaults)
+}
+
+// TracingConfig holds configuration for OpenTelemetry distributed tracing
+type TracingConfig struct {
+	Enabled     bool    // Whether spans are recorded and exported
+	Exporter    string  // Span exporter (otlp, stdout, file)
+	Endpoint    string  // OTLP/HTTP collector endpoint (host:port)
+	Insecure    bool    // Use plain HTTP for the OTLP endpoint
+	FilePath    string  // Output file for the file exporter
+	SampleRatio float64 // Fraction (0.0-1.0) of new traces sampled; parent decisions are respected
+	ServiceName string  // service.name resource attribute
 }
 
 // TaxBracket represents a single tax bracket with min, max, and rate
@@ -32,6 +148,15 @@ type TaxBracket struct {
 	Rate float64 `json:"rate"`
 }
 
+// BracketTax is the tax owed within a single bracket
+type BracketTax struct {
+	Min           float64 `json:"min"`
+	Max           float64 `json:"max,omitempty"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	Tax           float64 `json:"tax"`
+}
+
 // TaxCalcError represents an error returned by the tax calculator service
 type TaxCalcError struct {
 	Code    string `json:"code"`
@@ -41,13 +166,200 @@ type TaxCalcError struct {
 
 // TaxCalculatorResponse represents the response from the tax calculator service
 type TaxCalculatorResponse struct {
+	TaxBrackets   []TaxBracket           `json:"tax_brackets"`
+	Allowances    *Allowances            `json:"allowances,omitempty"`    // Omitted for years without deductions or credits
+	Jurisdictions []JurisdictionSchedule `json:"jurisdictions,omitempty"` // Schedules combined for a jurisdiction, e.g. federal and state; replace TaxBrackets and Allowances
+}
+
+// JurisdictionSchedule is the bracket schedule of one level of a jurisdiction
+type JurisdictionSchedule struct {
+	Code        string       `json:"code"` // e.g. US or US-CA
+	Name        string       `json:"name,omitempty"`
 	TaxBrackets []TaxBracket `json:"tax_brackets"`
+	Allowances  *Allowances  `json:"allowances,omitempty"`
+}
+
+// Allowances are the deductions and non-refundable credits of a tax year
+type Allowances struct {
+	CreditRate          float64                   `json:"credit_rate"`           // Rate at which credit amounts reduce tax
+	BasicPersonalAmount float64                   `json:"basic_personal_amount"` // Credit amount every taxpayer claims
+	DependentAmount     float64                   `json:"dependent_amount"`      // Credit amount per dependent
+	Deductions          map[string]DeductionLimit `json:"deductions,omitempty"`  // Deductions allowed, by type
+}
+
+// DeductionLimit caps a type of pre-tax deduction
+type DeductionLimit struct {
+	Max     float64 `json:"max,omitempty"`      // Most that can be deducted; 0 for no limit
+	MaxRate float64 `json:"max_rate,omitempty"` // Most that can be deducted as a share of salary; 0 for no limit
+}
+
+// BracketsResponse lists the tax brackets of a tax year
+type BracketsResponse struct {
+	Year     int               `json:"year"`
+	Brackets []ScheduleBracket `json:"brackets"`
+}
+
+// ScheduleBracket is a tax bracket as published by the brackets endpoint
+type ScheduleBracket struct {
+	Min           float64  `json:"min"`
+	Max           float64  `json:"max,omitempty"`
+	Rate          float64  `json:"rate"`
+	CumulativeTax *float64 `json:"cumulative_tax,omitempty"` // Total tax on a salary at Max; only when requested, never for the top bracket
+}
+
+// ComparisonResponse compares the tax on a salary across tax years
+type ComparisonResponse struct {
+	Salary float64   `json:"salary"`
+	Years  []YearTax `json:"years"` // In ascending year order
+}
+
+// YearTax is the tax on a salary in one tax year
+type YearTax struct {
+	Year          int         `json:"year"`
+	Tax           float64     `json:"tax"`
+	EffectiveRate float64     `json:"effective_rate"`
+	MarginalRate  float64     `json:"marginal_rate"`
+	Change        *YearChange `json:"change,omitempty"` // Compared with the previous year that succeeded
+	Error         string      `json:"error,omitempty"`  // Set when the year's brackets could not be fetched
+	Code          string      `json:"code,omitempty"`   // Machine-readable error code
+}
+
+// YearChange is the difference from an earlier tax year (this year minus that year)
+type YearChange struct {
+	FromYear      int     `json:"from_year"`
+	Tax           float64 `json:"tax"`
+	EffectiveRate float64 `json:"effective_rate"`
+	MarginalRate  float64 `json:"marginal_rate"`
+}
+
+// CurveResponse is the tax curve of a tax year, for charting tax against income
+type CurveResponse struct {
+	Year   int          `json:"year"`
+	Points []CurvePoint `json:"points"` // In ascending salary order
+}
+
+// CurvePoint is the tax on one salary of a curve
+type CurvePoint struct {
+	Salary        float64 `json:"salary"`
+	Tax           float64 `json:"tax"`
+	EffectiveRate float64 `json:"effective_rate"`
+	MarginalRate  float64 `json:"marginal_rate"`
+	Breakpoint    bool    `json:"breakpoint,omitempty"` // The salary is a bracket boundary, added so the curve bends in the right place
+}
+
+// Request is the JSON body of a single tax calculation request. Salary is a
+// pointer so a missing salary can be told apart from a zero salary.
+type Request struct {
+	Salary       *float64           `json:"salary"`
+	Year         int                `json:"year,omitempty"`
+	Deductions   map[string]float64 `json:"deductions,omitempty"` // Pre-tax deductions by type
+	Dependents   int                `json:"dependents,omitempty"`
+	Jurisdiction string             `json:"jurisdiction,omitempty"` // Requires includeJurisdiction
+}
+
+// Claims are the deductions and credits claimed against a salary
+type Claims struct {
+	Deductions map[string]float64 // Pre-tax deductions by type, such as retirement or union_dues
+	Dependents int                // Dependents claimed for the per-dependent credit
+}
+
+// NetTax is the tax on a salary after deductions and credits
+type NetTax struct {
+	Deductions    float64 // Deductions applied, after the year's limits
+	TaxableIncome float64 // Salary less deductions
+	GrossTax      float64 // Tax on the taxable income
+	Credits       float64 // Credits applied, never more than the gross tax
+	NetTax        float64 // Gross tax less credits
+	EffectiveRate float64 // Net tax divided by salary, rounded to 3 decimals
+}
+
+// Machine-readable error codes reported in problem responses and batch results
+const (
+	CodeValidation           = "validation_error"       // One or more fields are invalid (see the field errors)
+	CodeInvalidBody          = "invalid_body"           // The request body could not be decoded
+	CodeUnsupportedMediaType = "unsupported_media_type" // The request body has an unsupported Content-Type
+	CodePayloadTooLarge      = "payload_too_large"      // The request body or batch exceeds the configured limits
+	CodeMethodNotAllowed     = "method_not_allowed"     // The route does not support the request method
+	CodeTaxYearNotFound      = "tax_year_not_found"     // The tax calculator has no brackets for the year
+	CodeJurisdictionNotFound = "jurisdiction_not_found" // The tax calculator has no schedules for the jurisdiction (in the year)
+	CodeUpstreamUnavailable  = "upstream_unavailable"   // The circuit breaker is rejecting calls to the tax calculator
+	CodeUpstreamError        = "upstream_error"         // The tax calculator failed or returned an invalid response
+	CodeInternal             = "internal_error"         // An unexpected error occurred
+)
+
+// Problem is an RFC 7807 problem details error response (application/problem+json)
+type Problem struct {
+	Type      string       `json:"type"`               // URI identifying the problem type, derived from Code
+	Title     string       `json:"title"`              // Short summary of the problem type
+	Status    int          `json:"status"`             // HTTP status code
+	Detail    string       `json:"detail,omitempty"`   // Explanation of this occurrence, safe to show to clients
+	Instance  string       `json:"instance,omitempty"` // Request path
+	Code      string       `json:"code"`               // Machine-readable error code
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"` // Per-field validation errors
+}
+
+// FieldError describes an invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // Machine-readable reason (required, invalid, out_of_range)
+	Message string `json:"message"`
 }
 
 // Response represents the response structure
 type Response struct {
-	Salary        float64 `json:"salary"`
-	Tax           float64 `json:"tax,omitempty"`
-	EffectiveRate float64 `json:"effective_rate,omitempty"`
-	Error         string  `json:"error,omitempty"`
+	Salary              float64           `json:"salary"`
+	Deductions          float64           `json:"deductions"`
+	TaxableIncome       float64           `json:"taxable_income"`
+	GrossTax            float64           `json:"gross_tax"`
+	Credits             float64           `json:"credits"`
+	NetTax              float64           `json:"net_tax"`
+	Tax                 float64           `json:"tax,omitempty"` // Same as NetTax, kept for existing clients
+	EffectiveRate       float64           `json:"effective_rate,omitempty"`
+	MarginalRate        float64           `json:"marginal_rate"`
+	Bracket             *CurrentBracket   `json:"bracket,omitempty"`
+	IncomeToNextBracket *float64          `json:"income_to_next_bracket,omitempty"` // Omitted in the top bracket
+	TaxOnNextDollar     float64           `json:"tax_on_next_dollar"`
+	TaxOnNextThousand   float64           `json:"tax_on_next_thousand"`
+	Jurisdiction        string            `json:"jurisdiction,omitempty"`
+	Jurisdictions       []JurisdictionTax `json:"jurisdictions,omitempty"` // Per-schedule results when several schedules are combined
+}
+
+// JurisdictionTax is the tax owed to one level of a jurisdiction
+type JurisdictionTax struct {
+	Code                string          `json:"code"`
+	Name                string          `json:"name,omitempty"`
+	Deductions          float64         `json:"deductions"`
+	TaxableIncome       float64         `json:"taxable_income"`
+	GrossTax            float64         `json:"gross_tax"`
+	Credits             float64         `json:"credits"`
+	NetTax              float64         `json:"net_tax"`
+	EffectiveRate       float64         `json:"effective_rate"`
+	MarginalRate        float64         `json:"marginal_rate"`
+	Bracket             *CurrentBracket `json:"bracket,omitempty"`
+	IncomeToNextBracket *float64        `json:"income_to_next_bracket,omitempty"` // Omitted in the top bracket
+}
+
+// CombinedTax is the tax on a salary across the schedules of a jurisdiction
+type CombinedTax struct {
+	Jurisdictions []JurisdictionTax // One per schedule, in the provider's order
+	Total         NetTax            // Sums of the schedules; deductions and taxable income are those of the first schedule
+	Marginal      MarginalTax       // Combined rate and next-dollar tax; the income to the nearest bracket change
+}
+
+// CurrentBracket is the bracket taxing the next dollar earned above a salary
+type CurrentBracket struct {
+	Index int     `json:"index"` // 0-based position in the year's brackets
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max,omitempty"` // Omitted for the top bracket
+	Rate  float64 `json:"rate"`
+}
+
+// MarginalTax describes the tax on income earned above a salary
+type MarginalTax struct {
+	Rate                float64         // Rate applied to the next dollar
+	Bracket             *CurrentBracket // Bracket taxing the next dollar; nil below the first bracket
+	IncomeToNextBracket *float64        // Income left until the next bracket starts; nil in the top bracket
+	TaxOnNextDollar     float64         // Extra tax on one more dollar, rounded to cents
+	TaxOnNextThousand   float64         // Extra tax on one thousand more, rounded to cents
 }
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"pulsegrade/test1/models"
+)
+
+// ErrUnavailable is returned when the circuit breaker rejects a call to the tax calculator
+var ErrUnavailable = errors.New("tax calculator service is unavailable")
+
+// StatusError is returned when the tax calculator answers with a non-200 status
+type StatusError struct {
+	StatusCode   int    // Upstream HTTP status
+	TaxYear      int    // Tax year requested (0 for the upstream default year)
+	Jurisdiction string // Jurisdiction requested (empty for the upstream default)
+	Message      string // Upstream error details, for logs only
+}
+
+// Error returns the upstream error details
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// ErrorCode classifies an error returned by FetchTaxData into the machine-readable
+// code reported to clients, without exposing the upstream details. A 404 names
+// what the client asked for: the jurisdiction or the tax year. A 404 for the
+// upstream defaults is the tax calculator's fault, not the client's.
+func ErrorCode(err error) string {
+	var statusErr *StatusError
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		return models.CodeUpstreamUnavailable
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound && statusErr.Jurisdiction != "":
+		return models.CodeJurisdictionNotFound
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound && statusErr.TaxYear > 0:
+		return models.CodeTaxYearNotFound
+	default:
+		return models.CodeUpstreamError
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// CircuitBreakerState returns the current state of the circuit breaker
+// ("closed", "half-open" or "open"), or "disabled" when it is not enabled
+func (tc *TaxCalculator) CircuitBreakerState() string {
+	if !tc.cbEnabled || tc.cb == nil {
+		return "disabled"
+	}
+	return tc.cb.State().String()
+}
+
+// CheckCircuitBreaker returns an error while the circuit breaker is open and
+// requests to the tax calculator are being rejected
+func (tc *TaxCalculator) CheckCircuitBreaker(ctx context.Context) error {
+	if tc.cbEnabled && tc.cb != nil && tc.cb.State() == gobreaker.StateOpen {
+		return fmt.Errorf("circuit breaker is open")
+	}
+	return nil
+}
+
+// DefaultProbeCacheTTL is how long a probe result is reused, so that frequent
+// readiness checks do not each call the tax calculator
+const DefaultProbeCacheTTL = 10 * time.Second
+
+// probeCache remembers the latest probe result of each URL
+type probeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	results map[string]probeResult
+}
+
+// probeResult is the outcome of a probe and when it was made
+type probeResult struct {
+	err  error
+	time time.Time
+}
+
+// SetProbeCacheTTL sets how long probe results are reused; 0 probes every time
+func (tc *TaxCalculator) SetProbeCacheTTL(ttl time.Duration) {
+	tc.probes.mu.Lock()
+	defer tc.probes.mu.Unlock()
+	tc.probes.ttl = ttl
+	tc.probes.results = nil
+}
+
+// ProbeTaxData checks that the tax calculator at url responds with valid tax data,
+// as accepted for calculations. Probes bypass the circuit breaker and upstream
+// metrics so health checks neither trip the breaker nor skew the request statistics.
+// A result is reused for the probe cache TTL (DefaultProbeCacheTTL by default).
+func (tc *TaxCalculator) ProbeTaxData(ctx context.Context, url string) error {
+	tc.probes.mu.Lock()
+	cached, ok := tc.probes.results[url]
+	ttl := tc.probes.ttl
+	tc.probes.mu.Unlock()
+	if ok && time.Since(cached.time) < ttl {
+		return cached.err
+	}
+
+	err := probeTaxData(ctx, url)
+	if ttl > 0 && !errors.Is(err, context.Canceled) {
+		// A cancelled probe says nothing about the tax calculator
+		tc.probes.mu.Lock()
+		if tc.probes.results == nil {
+			tc.probes.results = map[string]probeResult{}
+		}
+		tc.probes.results[url] = probeResult{err: err, time: time.Now()}
+		tc.probes.mu.Unlock()
+	}
+	return err
+}
+
+// probeTaxData requests url and validates the tax data it returns
+func probeTaxData(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	_, _, _, err = requestTaxData(req, 0, true)
+	return err
+}
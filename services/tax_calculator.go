@@ -1,27 +1,41 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"pulsegrade/test1/logger"
 	"pulsegrade/test1/metrics"
 	"pulsegrade/test1/models"
+	"pulsegrade/test1/tracing"
 
 	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TaxCalculator provides tax calculation functionality
 type TaxCalculator struct {
-	cb          *gobreaker.CircuitBreaker
-	environment string
-	cbEnabled   bool // Flag indicating if circuit breaker is enabled
+	cb               *gobreaker.CircuitBreaker
+	environment      string
+	cbEnabled        bool // Flag indicating if circuit breaker is enabled
+	traceConnections bool // Flag indicating if connection-level timings are recorded
+	metrics          *metrics.Registry
+	probes           probeCache // Recent health probe results
 }
 
 // NewTaxCalculator creates a new TaxCalculator with a configured circuit breaker
@@ -51,14 +65,17 @@ func NewTaxCalculatorWithConfig(environment string, circuitBreakerEnabled bool)
 		MaxHalfOpenReqs:  100,
 	}
 
-	return NewTaxCalculatorWithFullConfig(environment, circuitBreakerEnabled, cbConfig)
+	return NewTaxCalculatorWithFullConfig(environment, circuitBreakerEnabled, cbConfig, metrics.Default())
 }
 
-// NewTaxCalculatorWithFullConfig creates a new TaxCalculator with complete configuration
-func NewTaxCalculatorWithFullConfig(environment string, circuitBreakerEnabled bool, cbConfig models.CircuitBreakerConfig) *TaxCalculator {
+// NewTaxCalculatorWithFullConfig creates a new TaxCalculator with complete configuration,
+// recording its metrics in the given registry
+func NewTaxCalculatorWithFullConfig(environment string, circuitBreakerEnabled bool, cbConfig models.CircuitBreakerConfig, registry *metrics.Registry) *TaxCalculator {
 	calculator := &TaxCalculator{
 		environment: environment,
 		cbEnabled:   circuitBreakerEnabled,
+		metrics:     registry,
+		probes:      probeCache{ttl: DefaultProbeCacheTTL},
 	}
 
 	if circuitBreakerEnabled {
@@ -74,6 +91,7 @@ func NewTaxCalculatorWithFullConfig(environment string, circuitBreakerEnabled bo
 				failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
 				return counts.Requests >= uint32(cbConfig.RequestThreshold) && failureRatio >= cbConfig.FailureRatio
 			},
+			IsSuccessful: isBreakerSuccess,
 			OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
 				logger.Info("Circuit breaker '%s' changed from '%v' to '%v' [threshold=%d, ratio=%.2f]",
 					name, from, to, cbConfig.RequestThreshold, cbConfig.FailureRatio)
@@ -88,24 +106,35 @@ func NewTaxCalculatorWithFullConfig(environment string, circuitBreakerEnabled bo
 				case gobreaker.StateOpen:
 					stateValue = 3
 				}
-				metrics.CircuitBreakerState.WithLabelValues(name, environment).Set(stateValue)
+				registry.CircuitBreakerState.WithLabelValues(name, environment).Set(stateValue)
 			},
 		}
 
 		calculator.cb = gobreaker.NewCircuitBreaker(settings)
 
 		// Initialize the circuit breaker state metric to "closed" (1)
-		metrics.CircuitBreakerState.WithLabelValues(cbName, environment).Set(1)
+		registry.CircuitBreakerState.WithLabelValues(cbName, environment).Set(1)
 	}
 
 	return calculator
 }
 
+// SetConnectionTracing enables or disables recording of DNS, connect, TLS and
+// time-to-first-byte timings for upstream calls
+func (tc *TaxCalculator) SetConnectionTracing(enabled bool) {
+	tc.traceConnections = enabled
+}
+
 // CalculateTax computes the tax amount based on salary and tax brackets
 func (tc *TaxCalculator) CalculateTax(salary float64, brackets []models.TaxBracket) (float64, float64) {
 	var totalTax float64 = 0
 	var effectiveRate float64 = 0
 
+	// Zero income owes no tax; returning early also avoids dividing by zero for the rate
+	if salary <= 0 {
+		return 0, 0
+	}
+
 	for _, bracket := range brackets {
 		// skip if we are below this bracket
 		if salary <= bracket.Min {
@@ -136,45 +165,473 @@ func (tc *TaxCalculator) CalculateTax(salary float64, brackets []models.TaxBrack
 	return totalTax, effectiveRate
 }
 
-// FetchTaxData retrieves tax bracket data from the tax calculator service
-func (tc *TaxCalculator) FetchTaxData(url string) (*models.TaxCalculatorResponse, error) {
+// CalculateBreakdown returns the tax owed within each bracket the salary reaches;
+// the amounts add up to the tax returned by CalculateTax
+func (tc *TaxCalculator) CalculateBreakdown(salary float64, brackets []models.TaxBracket) []models.BracketTax {
+	var breakdown []models.BracketTax
+
+	for _, bracket := range brackets {
+		// stop once the salary is below this bracket
+		if salary <= bracket.Min {
+			break
+		}
+
+		// the top bracket (no maximum) and the bracket containing the salary are only partly taxed
+		taxableAmount := salary - bracket.Min
+		if bracket.Max != 0 && salary > bracket.Max {
+			taxableAmount = bracket.Max - bracket.Min
+		}
+
+		breakdown = append(breakdown, models.BracketTax{
+			Min:           bracket.Min,
+			Max:           bracket.Max,
+			Rate:          bracket.Rate,
+			TaxableAmount: taxableAmount,
+			Tax:           taxableAmount * bracket.Rate,
+		})
+	}
+
+	return breakdown
+}
+
+// MarginalRate returns the rate applied to the next dollar earned above salary:
+// the rate of the bracket starting at or below salary and ending above it
+func (tc *TaxCalculator) MarginalRate(salary float64, brackets []models.TaxBracket) float64 {
+	if index := bracketIndex(salary, brackets); index >= 0 {
+		return brackets[index].Rate
+	}
+	return 0
+}
+
+// CalculateMarginal describes the tax on income earned above salary: the
+// bracket taxing the next dollar, the income left until the next bracket and
+// the extra tax on the next dollar and the next thousand (which may span brackets)
+func (tc *TaxCalculator) CalculateMarginal(salary float64, brackets []models.TaxBracket) models.MarginalTax {
+	var marginal models.MarginalTax
+	index := bracketIndex(salary, brackets)
+	if index < 0 {
+		return marginal
+	}
+
+	bracket := brackets[index]
+	marginal.Rate = bracket.Rate
+	marginal.Bracket = &models.CurrentBracket{Index: index, Min: bracket.Min, Max: bracket.Max, Rate: bracket.Rate}
+	if bracket.Max != 0 {
+		remaining := bracket.Max - salary
+		marginal.IncomeToNextBracket = &remaining
+	}
+
+	tax, _ := tc.CalculateTax(salary, brackets)
+	nextDollar, _ := tc.CalculateTax(salary+1, brackets)
+	nextThousand, _ := tc.CalculateTax(salary+1000, brackets)
+	marginal.TaxOnNextDollar = math.Round((nextDollar-tax)*100) / 100
+	marginal.TaxOnNextThousand = math.Round((nextThousand-tax)*100) / 100
+	return marginal
+}
+
+// CalculateNet applies claims to a salary using a tax year's data: deductions,
+// capped by the year's limits and by the salary, reduce the taxable income;
+// the brackets tax what is left; and the non-refundable credits (the basic
+// personal amount plus one amount per dependent, at the credit rate) reduce
+// that tax, never below zero. Deductions not allowed by the year are ignored;
+// see DisallowedDeductions.
+func (tc *TaxCalculator) CalculateNet(salary float64, claims models.Claims, data *models.TaxCalculatorResponse) models.NetTax {
+	var net models.NetTax
+	allowances := data.Allowances
+
+	if allowances != nil {
+		for name, amount := range claims.Deductions {
+			limit, ok := allowances.Deductions[name]
+			if !ok {
+				continue
+			}
+			if limit.Max > 0 {
+				amount = math.Min(amount, limit.Max)
+			}
+			if limit.MaxRate > 0 {
+				amount = math.Min(amount, salary*limit.MaxRate)
+			}
+			net.Deductions += amount
+		}
+	}
+	net.Deductions = math.Round(math.Min(net.Deductions, salary)*100) / 100
+	net.TaxableIncome = salary - net.Deductions
+
+	net.GrossTax, _ = tc.CalculateTax(net.TaxableIncome, data.TaxBrackets)
+	net.GrossTax = math.Round(net.GrossTax*100) / 100
+	if allowances != nil {
+		credits := (allowances.BasicPersonalAmount + float64(claims.Dependents)*allowances.DependentAmount) * allowances.CreditRate
+		net.Credits = math.Round(math.Min(credits, net.GrossTax)*100) / 100
+	}
+	net.NetTax = math.Round((net.GrossTax-net.Credits)*100) / 100
+
+	if salary > 0 {
+		net.EffectiveRate = math.Round((net.NetTax/salary)*1000) / 1000 // Rounded to 3 decimal places
+	}
+	return net
+}
+
+// CalculateNetMarginal describes the tax on income earned above salary once
+// claims are applied: the bracket and the income to the next bracket follow the
+// taxable income, while the tax on the next dollar and thousand is the change
+// in net tax, so it is 0 while credits still cover the tax
+func (tc *TaxCalculator) CalculateNetMarginal(salary float64, claims models.Claims, data *models.TaxCalculatorResponse) models.MarginalTax {
+	net := tc.CalculateNet(salary, claims, data)
+	marginal := tc.CalculateMarginal(net.TaxableIncome, data.TaxBrackets)
+
+	nextDollar := tc.CalculateNet(salary+1, claims, data)
+	nextThousand := tc.CalculateNet(salary+1000, claims, data)
+	marginal.TaxOnNextDollar = math.Round((nextDollar.NetTax-net.NetTax)*100) / 100
+	marginal.TaxOnNextThousand = math.Round((nextThousand.NetTax-net.NetTax)*100) / 100
+	return marginal
+}
+
+// DisallowedDeductions returns, sorted, the claimed deduction types that no
+// schedule of the tax data allows (every type when there are no allowances)
+func DisallowedDeductions(claims models.Claims, data *models.TaxCalculatorResponse) []string {
+	var disallowed []string
+	for name := range claims.Deductions {
+		allowed := false
+		for _, schedule := range Schedules(data) {
+			if schedule.Allowances != nil {
+				if _, ok := schedule.Allowances.Deductions[name]; ok {
+					allowed = true
+					break
+				}
+			}
+		}
+		if !allowed {
+			disallowed = append(disallowed, name)
+		}
+	}
+	sort.Strings(disallowed)
+	return disallowed
+}
+
+// Schedules returns the bracket schedules of tax data: its jurisdictions or,
+// for a single schedule, the brackets and allowances of the data itself
+func Schedules(data *models.TaxCalculatorResponse) []models.JurisdictionSchedule {
+	if len(data.Jurisdictions) > 0 {
+		return data.Jurisdictions
+	}
+	return []models.JurisdictionSchedule{{TaxBrackets: data.TaxBrackets, Allowances: data.Allowances}}
+}
+
+// CalculateCombined applies claims to a salary under every schedule of the tax
+// data (e.g. federal and state) and combines the results: the tax and credits
+// add up, the marginal rates add up and the income to the next bracket is the
+// income until any schedule changes rate. Each schedule applies its own
+// deductions and credits.
+func (tc *TaxCalculator) CalculateCombined(salary float64, claims models.Claims, data *models.TaxCalculatorResponse) models.CombinedTax {
+	var combined models.CombinedTax
+	for i, schedule := range Schedules(data) {
+		scheduleData := &models.TaxCalculatorResponse{TaxBrackets: schedule.TaxBrackets, Allowances: schedule.Allowances}
+		net := tc.CalculateNet(salary, claims, scheduleData)
+		marginal := tc.CalculateNetMarginal(salary, claims, scheduleData)
+
+		combined.Jurisdictions = append(combined.Jurisdictions, models.JurisdictionTax{
+			Code:                schedule.Code,
+			Name:                schedule.Name,
+			Deductions:          net.Deductions,
+			TaxableIncome:       net.TaxableIncome,
+			GrossTax:            net.GrossTax,
+			Credits:             net.Credits,
+			NetTax:              net.NetTax,
+			EffectiveRate:       net.EffectiveRate,
+			MarginalRate:        marginal.Rate,
+			Bracket:             marginal.Bracket,
+			IncomeToNextBracket: marginal.IncomeToNextBracket,
+		})
+
+		if i == 0 {
+			combined.Total.Deductions, combined.Total.TaxableIncome = net.Deductions, net.TaxableIncome
+			combined.Marginal.Bracket = marginal.Bracket
+		} else {
+			combined.Marginal.Bracket = nil // A single bracket only describes a single schedule
+		}
+		combined.Total.GrossTax += net.GrossTax
+		combined.Total.Credits += net.Credits
+		combined.Total.NetTax += net.NetTax
+
+		combined.Marginal.Rate += marginal.Rate
+		combined.Marginal.TaxOnNextDollar += marginal.TaxOnNextDollar
+		combined.Marginal.TaxOnNextThousand += marginal.TaxOnNextThousand
+		if next := marginal.IncomeToNextBracket; next != nil && (combined.Marginal.IncomeToNextBracket == nil || *next < *combined.Marginal.IncomeToNextBracket) {
+			combined.Marginal.IncomeToNextBracket = next
+		}
+	}
+
+	// Round the sums so floating point noise does not show in responses
+	combined.Total.GrossTax = math.Round(combined.Total.GrossTax*100) / 100
+	combined.Total.Credits = math.Round(combined.Total.Credits*100) / 100
+	combined.Total.NetTax = math.Round(combined.Total.NetTax*100) / 100
+	if salary > 0 {
+		combined.Total.EffectiveRate = math.Round((combined.Total.NetTax/salary)*1000) / 1000
+	}
+	combined.Marginal.Rate = math.Round(combined.Marginal.Rate*10000) / 10000
+	combined.Marginal.TaxOnNextDollar = math.Round(combined.Marginal.TaxOnNextDollar*100) / 100
+	combined.Marginal.TaxOnNextThousand = math.Round(combined.Marginal.TaxOnNextThousand*100) / 100
+	return combined
+}
+
+// bracketIndex returns the index of the bracket taxing the next dollar earned
+// above salary, or -1 when salary is below the first bracket
+func bracketIndex(salary float64, brackets []models.TaxBracket) int {
+	index := -1
+	for i, bracket := range brackets {
+		if salary < bracket.Min {
+			break
+		}
+		index = i
+		if bracket.Max == 0 || salary < bracket.Max {
+			break
+		}
+	}
+	return index
+}
+
+// contiguous reports whether a bracket starting at start follows one ending at end.
+// Boundaries are compared in whole cents and may be a cent apart: providers publish
+// boundaries such as 50000 followed by 50000.01, and decoding adds floating point noise.
+func contiguous(end, start float64) bool {
+	return math.Abs(math.Round(start*100)-math.Round(end*100)) <= 1
+}
+
+// ValidateBrackets checks that brackets form a usable tax schedule: at least one
+// bracket, ascending and contiguous (each bracket starts where the previous one
+// ends, within a cent), rates between 0 and 1, and only the last bracket without a maximum
+func ValidateBrackets(brackets []models.TaxBracket) error {
+	if len(brackets) == 0 {
+		return fmt.Errorf("no tax brackets")
+	}
+
+	for i, bracket := range brackets {
+		switch {
+		case bracket.Min < 0:
+			return fmt.Errorf("bracket %d has a negative minimum %v", i+1, bracket.Min)
+		case bracket.Rate < 0 || bracket.Rate > 1 || math.IsNaN(bracket.Rate):
+			return fmt.Errorf("bracket %d has a rate %v outside [0, 1]", i+1, bracket.Rate)
+		case bracket.Max == 0 && i != len(brackets)-1:
+			return fmt.Errorf("bracket %d has no maximum but is not the last bracket", i+1)
+		case bracket.Max != 0 && bracket.Max <= bracket.Min:
+			return fmt.Errorf("bracket %d ends at %v, not above its minimum %v", i+1, bracket.Max, bracket.Min)
+		case i > 0 && !contiguous(brackets[i-1].Max, bracket.Min):
+			return fmt.Errorf("bracket %d starts at %v but the previous bracket ends at %v", i+1, bracket.Min, brackets[i-1].Max)
+		}
+	}
+	return nil
+}
+
+// ValidateAllowances checks that a tax year's deductions and credits are
+// usable: a credit rate between 0 and 1 and no negative amounts or limits
+func ValidateAllowances(allowances *models.Allowances) error {
+	if allowances == nil {
+		return nil
+	}
+
+	switch {
+	case allowances.CreditRate < 0 || allowances.CreditRate > 1 || math.IsNaN(allowances.CreditRate):
+		return fmt.Errorf("credit rate %v is outside [0, 1]", allowances.CreditRate)
+	case allowances.BasicPersonalAmount < 0:
+		return fmt.Errorf("basic personal amount %v is negative", allowances.BasicPersonalAmount)
+	case allowances.DependentAmount < 0:
+		return fmt.Errorf("dependent amount %v is negative", allowances.DependentAmount)
+	}
+	for name, limit := range allowances.Deductions {
+		if limit.Max < 0 || limit.MaxRate < 0 || limit.MaxRate > 1 {
+			return fmt.Errorf("deduction %q has an invalid limit (max %v, max rate %v)", name, limit.Max, limit.MaxRate)
+		}
+	}
+	return nil
+}
+
+// ValidateTaxData checks the schedules of tax data: the brackets and allowances
+// of the data itself or, when it lists jurisdictions and jurisdictions are
+// accepted, of each jurisdiction. Without jurisdictions the data's own brackets
+// are required even when it lists jurisdictions, since they are all the caller reads.
+func ValidateTaxData(data *models.TaxCalculatorResponse, jurisdictions bool) error {
+	if !jurisdictions && len(data.TaxBrackets) == 0 && len(data.Jurisdictions) > 0 {
+		return fmt.Errorf("only per-jurisdiction tax brackets, which are not used without a jurisdiction")
+	}
+	if !jurisdictions || len(data.Jurisdictions) == 0 {
+		if err := ValidateBrackets(data.TaxBrackets); err != nil {
+			return fmt.Errorf("invalid tax brackets: %v", err)
+		}
+		if err := ValidateAllowances(data.Allowances); err != nil {
+			return fmt.Errorf("invalid allowances: %v", err)
+		}
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for i, schedule := range data.Jurisdictions {
+		switch {
+		case schedule.Code == "":
+			return fmt.Errorf("jurisdiction %d has no code", i+1)
+		case seen[schedule.Code]:
+			return fmt.Errorf("jurisdiction %s is listed twice", schedule.Code)
+		}
+		seen[schedule.Code] = true
+		if err := ValidateBrackets(schedule.TaxBrackets); err != nil {
+			return fmt.Errorf("invalid tax brackets for jurisdiction %s: %v", schedule.Code, err)
+		}
+		if err := ValidateAllowances(schedule.Allowances); err != nil {
+			return fmt.Errorf("invalid allowances for jurisdiction %s: %v", schedule.Code, err)
+		}
+	}
+	return nil
+}
+
+// ResolveTaxYear returns the tax year whose brackets should be requested: 0 (the
+// upstream default year) unless includeTaxYear is set, in which case the requested
+// year or, when none was given, the current year
+func ResolveTaxYear(includeTaxYear bool, requestedYear int) int {
+	if !includeTaxYear {
+		return 0
+	}
+	if requestedYear > 0 {
+		return requestedYear
+	}
+	return time.Now().Year()
+}
+
+// TaxDataURL returns the tax calculator URL serving the brackets of taxYear
+// (0 means the upstream default year)
+func TaxDataURL(baseURL string, taxYear int) string {
+	return JurisdictionTaxDataURL(baseURL, "", taxYear)
+}
+
+// JurisdictionTaxDataURL returns the tax calculator URL serving the schedules of
+// a jurisdiction for taxYear; an empty jurisdiction is the upstream default
+func JurisdictionTaxDataURL(baseURL, jurisdiction string, taxYear int) string {
+	if jurisdiction != "" {
+		baseURL = fmt.Sprintf("%s/jurisdiction/%s", baseURL, url.PathEscape(jurisdiction))
+	}
+	if taxYear <= 0 {
+		return baseURL
+	}
+	return fmt.Sprintf("%s/tax-year/%d", baseURL, taxYear)
+}
+
+// FetchTaxData retrieves tax bracket data from the tax calculator service.
+// taxYear is only used to label metrics and spans; 0 means the upstream default year.
+// The data must have top-level brackets: callers of FetchTaxData read a single
+// schedule, so data that only lists jurisdictions is rejected as invalid.
+func (tc *TaxCalculator) FetchTaxData(ctx context.Context, url string, taxYear int) (*models.TaxCalculatorResponse, error) {
+	return tc.fetch(ctx, url, taxYear, false)
+}
+
+// fetch retrieves tax data, accepting data whose schedules are only listed per
+// jurisdiction when jurisdictions is set
+func (tc *TaxCalculator) fetch(ctx context.Context, url string, taxYear int, jurisdictions bool) (*models.TaxCalculatorResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "FetchTaxData", trace.WithAttributes(
+		attribute.String("tax.year", metrics.TaxYearLabel(taxYear)),
+		attribute.Bool("circuit_breaker.enabled", tc.cbEnabled && tc.cb != nil),
+	))
+	defer span.End()
+
+	response, err := tc.fetchTaxData(ctx, span, url, taxYear, jurisdictions)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// The bracket data in use is as old as the last successful fetch of its year
+	tc.metrics.TaxBracketFetchTime.WithLabelValues(metrics.TaxYearLabel(taxYear), tc.environment).SetToCurrentTime()
+	return response, nil
+}
+
+// FetchJurisdictionTaxData retrieves the schedules of a jurisdiction (empty for
+// the upstream default) for taxYear (0 for the default year) through FetchTaxData.
+// Unlike FetchTaxData it accepts data listing only jurisdictions; combine their
+// schedules with CalculateCombined. A 404 is reported as an unknown jurisdiction
+// rather than an unknown year.
+func (tc *TaxCalculator) FetchJurisdictionTaxData(ctx context.Context, baseURL, jurisdiction string, taxYear int) (*models.TaxCalculatorResponse, error) {
+	taxData, err := tc.fetch(ctx, JurisdictionTaxDataURL(baseURL, jurisdiction, taxYear), taxYear, true)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		statusErr.Jurisdiction = jurisdiction
+	}
+	return taxData, err
+}
+
+// YearBrackets holds the brackets of one tax year, or the error fetching them
+type YearBrackets struct {
+	Year     int
+	Brackets []models.TaxBracket
+	Err      error
+}
+
+// FetchYears fetches the brackets of several tax years in parallel through
+// FetchTaxData (and so through the circuit breaker), returning them in the
+// order of years. A failed year carries its error; the others are unaffected.
+func (tc *TaxCalculator) FetchYears(ctx context.Context, baseURL string, years []int) []YearBrackets {
+	results := make([]YearBrackets, len(years))
+	var wg sync.WaitGroup
+	for i, year := range years {
+		wg.Add(1)
+		go func(i, year int) {
+			defer wg.Done()
+			results[i] = YearBrackets{Year: year}
+			taxData, err := tc.FetchTaxData(ctx, TaxDataURL(baseURL, year), year)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			results[i].Brackets = taxData.TaxBrackets
+		}(i, year)
+	}
+	wg.Wait()
+	return results
+}
+
+// fetchTaxData runs the upstream call through the circuit breaker when enabled,
+// recording the breaker decision on the span
+func (tc *TaxCalculator) fetchTaxData(ctx context.Context, span trace.Span, url string, taxYear int, jurisdictions bool) (*models.TaxCalculatorResponse, error) {
 
 	if tc.cbEnabled && tc.cb != nil {
+		span.SetAttributes(attribute.String("circuit_breaker.state", tc.cb.State().String()))
+
 		// Execute the request through the circuit breaker if enabled
 		response, err := tc.cb.Execute(func() (interface{}, error) {
-			return tc.doFetchTaxData(url)
+			return tc.doFetchTaxData(ctx, url, taxYear, jurisdictions)
 		})
 
 		if err != nil {
 			if err == gobreaker.ErrOpenState {
 				// Record rejected request due to open circuit
-				metrics.CircuitBreakerRejected.WithLabelValues("tax-service", tc.environment).Inc()
-				return nil, fmt.Errorf("tax calculator service is unavailable (circuit open): too many recent failures")
+				span.SetAttributes(attribute.String("circuit_breaker.decision", "rejected"))
+				tc.metrics.CircuitBreakerRejected.WithLabelValues("tax-service", tc.environment).Inc()
+				return nil, fmt.Errorf("%w (circuit open): too many recent failures", ErrUnavailable)
 			} else if err == gobreaker.ErrTooManyRequests {
-				metrics.CircuitBreakerRejected.WithLabelValues("tax-service", tc.environment).Inc()
-				return nil, fmt.Errorf("tax calculator service is unavailable: too many concurrent requests")
+				span.SetAttributes(attribute.String("circuit_breaker.decision", "rejected"))
+				tc.metrics.CircuitBreakerRejected.WithLabelValues("tax-service", tc.environment).Inc()
+				return nil, fmt.Errorf("%w: too many concurrent requests", ErrUnavailable)
 			}
 
 			// Record failure but not a rejection (normal error)
-			metrics.CircuitBreakerRequests.WithLabelValues("tax-service", "false", tc.environment).Inc()
-			metrics.TaxServiceErrors.WithLabelValues(tc.environment).Inc()
+			span.SetAttributes(attribute.String("circuit_breaker.decision", "allowed"))
+			tc.metrics.CircuitBreakerRequests.WithLabelValues("tax-service", "false", tc.environment).Inc()
+			tc.metrics.TaxServiceErrors.WithLabelValues(tc.environment).Inc()
 
-			return nil, fmt.Errorf("tax calculator service error: %v", err)
+			return nil, fmt.Errorf("tax calculator service error: %w", err)
 		}
 
 		// Record successful request
-		metrics.CircuitBreakerRequests.WithLabelValues("tax-service", "true", tc.environment).Inc()
+		span.SetAttributes(attribute.String("circuit_breaker.decision", "allowed"))
+		tc.metrics.CircuitBreakerRequests.WithLabelValues("tax-service", "true", tc.environment).Inc()
 
 		// Cast the response back to the expected type
 		return response.(*models.TaxCalculatorResponse), nil
 	} else {
 		// If circuit breaker is disabled, call the fetch method directly
-		response, err := tc.doFetchTaxData(url)
+		response, err := tc.doFetchTaxData(ctx, url, taxYear, jurisdictions)
 
 		if err != nil {
 			// Still track errors in metrics
-			metrics.TaxServiceErrors.WithLabelValues(tc.environment).Inc()
-			return nil, fmt.Errorf("tax calculator service error: %v", err)
+			tc.metrics.TaxServiceErrors.WithLabelValues(tc.environment).Inc()
+			return nil, fmt.Errorf("tax calculator service error: %w", err)
 		}
 
 		return response, nil
@@ -183,21 +640,60 @@ func (tc *TaxCalculator) FetchTaxData(url string) (*models.TaxCalculatorResponse
 
 // doFetchTaxData performs the actual HTTP request to the tax service
 // This is wrapped by the circuit breaker in FetchTaxData
-func (tc *TaxCalculator) doFetchTaxData(url string) (*models.TaxCalculatorResponse, error) {
+func (tc *TaxCalculator) doFetchTaxData(ctx context.Context, url string, taxYear int, jurisdictions bool) (*models.TaxCalculatorResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GET tax-calculator", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	// Record latency and outcome of the upstream call once it completes
+	statusCode := 0
+	errorKind := upstreamErrorNone
+	startTime := time.Now()
+	defer func() {
+		yearLabel := metrics.TaxYearLabel(taxYear)
+		statusClass := statusClassLabel(statusCode)
+		metrics.ObserveWithTrace(ctx, tc.metrics.UpstreamRequestDuration.WithLabelValues(yearLabel, statusClass, tc.environment), time.Since(startTime).Seconds())
+		tc.metrics.UpstreamRequestsTotal.WithLabelValues(yearLabel, statusClass, errorKind, tc.environment).Inc()
+
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", statusCode),
+			attribute.String("upstream.error_kind", errorKind),
+		)
+		if errorKind != upstreamErrorNone {
+			span.SetStatus(codes.Error, errorKind)
+		}
+	}()
 
 	// Create a new request
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
+		errorKind = upstreamErrorRequest
 		return nil, err
 	}
 
+	// Propagate the trace context to the upstream tax calculator (W3C traceparent)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	// Optionally record connection-level timings
+	if tc.traceConnections {
+		req = tc.withConnectionTrace(req)
+	}
+
+	var taxData *models.TaxCalculatorResponse
+	taxData, statusCode, errorKind, err = requestTaxData(req, taxYear, jurisdictions)
+	return taxData, err
+}
+
+// requestTaxData sends a tax data request to the tax calculator and decodes and
+// validates the response. It returns the response status code (0 when none was
+// received) and the upstream error kind for metrics, which callers may ignore.
+func requestTaxData(req *http.Request, taxYear int, jurisdictions bool) (*models.TaxCalculatorResponse, int, string, error) {
 	// Send request with a more reasonable timeout
 	client := &http.Client{Timeout: 35 * time.Second}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Error("===> Error forwarding request: %v", err)
-		return nil, err
+		return nil, 0, classifyTransportError(err), err
 	}
 	defer resp.Body.Close()
 
@@ -217,31 +713,31 @@ func (tc *TaxCalculator) doFetchTaxData(url string) (*models.TaxCalculatorRespon
 					errorMsg := fmt.Sprintf("%s: %s", taxError.Code, taxError.Message)
 					errorMessages = append(errorMessages, errorMsg)
 				}
-				return nil, fmt.Errorf("tax calculator service error: %s", strings.Join(errorMessages, "; "))
+				return nil, resp.StatusCode, upstreamErrorStatus, &StatusError{StatusCode: resp.StatusCode, TaxYear: taxYear, Message: fmt.Sprintf("tax calculator service error: %s", strings.Join(errorMessages, "; "))}
 			}
 			// Fallback to using raw error body
-			return nil, fmt.Errorf("tax calculator service returned: %d - Details: %s", resp.StatusCode, string(errorBody))
+			return nil, resp.StatusCode, upstreamErrorStatus, &StatusError{StatusCode: resp.StatusCode, TaxYear: taxYear, Message: fmt.Sprintf("tax calculator service returned: %d - Details: %s", resp.StatusCode, string(errorBody))}
 		}
-		return nil, fmt.Errorf("tax calculator service returned error code: %d", resp.StatusCode)
+		return nil, resp.StatusCode, upstreamErrorStatus, &StatusError{StatusCode: resp.StatusCode, TaxYear: taxYear, Message: fmt.Sprintf("tax calculator service returned error code: %d", resp.StatusCode)}
 	}
 
 	// Read and parse response
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		logger.Error("===> Error reading response body: %v", err)
-		return nil, err
+		return nil, resp.StatusCode, classifyReadError(err), err
 	}
 
 	// Parse tax brackets from response
 	var taxResponse models.TaxCalculatorResponse
 	if err := json.Unmarshal(body, &taxResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse tax calculator response: %v", err)
+		return nil, resp.StatusCode, upstreamErrorDecode, fmt.Errorf("failed to parse tax calculator response: %v", err)
 	}
 
 	// Validate response
-	if len(taxResponse.TaxBrackets) == 0 {
-		return nil, fmt.Errorf("no tax brackets returned from tax calculator")
+	if err := ValidateTaxData(&taxResponse, jurisdictions); err != nil {
+		return nil, resp.StatusCode, upstreamErrorValidation, fmt.Errorf("invalid tax data returned from tax calculator: %v", err)
 	}
 
-	return &taxResponse, nil
+	return &taxResponse, resp.StatusCode, upstreamErrorNone, nil
 }
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"pulsegrade/test1/models"
@@ -28,6 +30,16 @@ func TestCalculateTax(t *testing.T) {
 			expectedTax:           10000, // 50000 * 0.2 = 10000
 			expectedEffectiveRate: 0.2,   // 10000 / 50000 = 0.2
 		},
+		{
+			name:   "zero income",
+			salary: 0,
+			brackets: []models.TaxBracket{
+				{Min: 0, Max: 30000, Rate: 0.1},
+				{Min: 30000, Max: 0, Rate: 0.2},
+			},
+			expectedTax:           0,
+			expectedEffectiveRate: 0, // No division by zero
+		},
 		{
 			name:   "tax calculation multiple brackets",
 			salary: 80000,
@@ -74,6 +86,15 @@ func TestCalculateTax(t *testing.T) {
 				t.Errorf("expected effective rate %f but got %f", tc.expectedEffectiveRate, effectiveRate)
 			}
 
+			// The per-bracket breakdown must add up to the total
+			var breakdownTax float64
+			for _, bracket := range calculator.CalculateBreakdown(tc.salary, tc.brackets) {
+				breakdownTax += bracket.Tax
+			}
+			if diff := abs(breakdownTax - tc.expectedTax); diff > 0.000001 {
+				t.Errorf("expected breakdown to add up to %f but got %f", tc.expectedTax, breakdownTax)
+			}
+
 			// // Adding test for effective rate with small tolerance for floating point precision
 			// if diff := abs(effectiveRate - tc.expectedEffectiveRate); diff > 0.000001 {
 			// 	t.Errorf("expected effective rate %f but got %f", tc.expectedEffectiveRate, effectiveRate)
@@ -90,6 +111,324 @@ func abs(x float64) float64 {
 	return x
 }
 
+func TestMarginalRate(t *testing.T) {
+	calculator := NewTaxCalculator()
+	brackets := []models.TaxBracket{
+		{Min: 0, Max: 30000, Rate: 0.1},
+		{Min: 30000, Max: 70000, Rate: 0.2},
+		{Min: 70000, Rate: 0.3},
+	}
+
+	tests := []struct {
+		salary float64
+		rate   float64
+	}{
+		{salary: 0, rate: 0.1},
+		{salary: 29999.99, rate: 0.1},
+		{salary: 30000, rate: 0.2}, // The next dollar falls in the second bracket
+		{salary: 69999, rate: 0.2},
+		{salary: 70000, rate: 0.3},
+		{salary: 1e6, rate: 0.3},
+	}
+
+	for _, tc := range tests {
+		if rate := calculator.MarginalRate(tc.salary, brackets); rate != tc.rate {
+			t.Errorf("expected marginal rate %v at %v but got %v", tc.rate, tc.salary, rate)
+		}
+	}
+}
+
+func TestCalculateMarginal(t *testing.T) {
+	calculator := NewTaxCalculator()
+	brackets := []models.TaxBracket{
+		{Min: 0, Max: 30000, Rate: 0.1},
+		{Min: 30000, Max: 70000, Rate: 0.2},
+		{Min: 70000, Rate: 0.3},
+	}
+
+	tests := []struct {
+		name         string
+		salary       float64
+		index        int
+		toNext       *float64
+		nextDollar   float64
+		nextThousand float64
+	}{
+		{name: "first bracket", salary: 10000, index: 0, toNext: floatPtr(20000), nextDollar: 0.1, nextThousand: 100},
+		{name: "boundary", salary: 30000, index: 1, toNext: floatPtr(40000), nextDollar: 0.2, nextThousand: 200},
+		{name: "spans brackets", salary: 69500, index: 1, toNext: floatPtr(500), nextDollar: 0.2, nextThousand: 250},
+		{name: "top bracket", salary: 100000, index: 2, nextDollar: 0.3, nextThousand: 300},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			marginal := calculator.CalculateMarginal(tc.salary, brackets)
+			if marginal.Bracket == nil || marginal.Bracket.Index != tc.index {
+				t.Fatalf("expected bracket %d but got %+v", tc.index, marginal.Bracket)
+			}
+			if marginal.Rate != brackets[tc.index].Rate {
+				t.Errorf("expected rate %v but got %v", brackets[tc.index].Rate, marginal.Rate)
+			}
+			switch {
+			case tc.toNext == nil && marginal.IncomeToNextBracket != nil:
+				t.Errorf("expected no next bracket but got %v", *marginal.IncomeToNextBracket)
+			case tc.toNext != nil && (marginal.IncomeToNextBracket == nil || *marginal.IncomeToNextBracket != *tc.toNext):
+				t.Errorf("expected %v to the next bracket but got %v", *tc.toNext, marginal.IncomeToNextBracket)
+			}
+			if marginal.TaxOnNextDollar != tc.nextDollar || marginal.TaxOnNextThousand != tc.nextThousand {
+				t.Errorf("expected %v and %v on the next dollar and thousand but got %v and %v",
+					tc.nextDollar, tc.nextThousand, marginal.TaxOnNextDollar, marginal.TaxOnNextThousand)
+			}
+		})
+	}
+
+	// Below the first bracket no bracket applies yet
+	if marginal := calculator.CalculateMarginal(500, []models.TaxBracket{{Min: 1000, Rate: 0.1}}); marginal.Bracket != nil {
+		t.Errorf("expected no bracket below the first bracket but got %+v", marginal.Bracket)
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func TestValidateBrackets(t *testing.T) {
+	tests := []struct {
+		name     string
+		brackets []models.TaxBracket
+		valid    bool
+	}{
+		{name: "Valid", brackets: []models.TaxBracket{{Min: 0, Max: 50000, Rate: 0.1}, {Min: 50000, Rate: 0.2}}, valid: true},
+		{name: "Single open bracket", brackets: []models.TaxBracket{{Min: 0, Rate: 0.15}}, valid: true},
+		{name: "Boundary a cent apart", brackets: []models.TaxBracket{{Min: 0, Max: 50000, Rate: 0.1}, {Min: 50000.01, Rate: 0.2}}, valid: true},
+		{name: "Boundary with rounding noise", brackets: []models.TaxBracket{{Min: 0, Max: 0.1 + 0.2, Rate: 0.1}, {Min: 0.3, Rate: 0.2}}, valid: true},
+		{name: "Empty", brackets: nil},
+		{name: "Gap of two cents", brackets: []models.TaxBracket{{Min: 0, Max: 50000, Rate: 0.1}, {Min: 50000.02, Rate: 0.2}}},
+		{name: "Gap", brackets: []models.TaxBracket{{Min: 0, Max: 50000, Rate: 0.1}, {Min: 60000, Rate: 0.2}}},
+		{name: "Overlap", brackets: []models.TaxBracket{{Min: 0, Max: 50000, Rate: 0.1}, {Min: 40000, Rate: 0.2}}},
+		{name: "Open bracket not last", brackets: []models.TaxBracket{{Min: 0, Rate: 0.1}, {Min: 50000, Rate: 0.2}}},
+		{name: "Empty range", brackets: []models.TaxBracket{{Min: 50000, Max: 50000, Rate: 0.1}}},
+		{name: "Rate above 1", brackets: []models.TaxBracket{{Min: 0, Rate: 15}}},
+		{name: "Negative minimum", brackets: []models.TaxBracket{{Min: -1, Rate: 0.1}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateBrackets(tc.brackets)
+			if tc.valid && err != nil {
+				t.Errorf("expected valid brackets but got: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestCalculateNet(t *testing.T) {
+	calculator := NewTaxCalculator()
+	data := &models.TaxCalculatorResponse{
+		TaxBrackets: []models.TaxBracket{
+			{Min: 0, Max: 50000, Rate: 0.15},
+			{Min: 50000, Rate: 0.25},
+		},
+		Allowances: &models.Allowances{
+			CreditRate:          0.15,
+			BasicPersonalAmount: 15000,
+			DependentAmount:     2000,
+			Deductions: map[string]models.DeductionLimit{
+				"retirement": {Max: 10000, MaxRate: 0.18},
+				"union_dues": {},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		salary float64
+		claims models.Claims
+		want   models.NetTax
+	}{
+		{
+			// Only the basic personal amount: 15000 * 0.15 = 2250 off 13750
+			name: "no claims", salary: 75000,
+			want: models.NetTax{TaxableIncome: 75000, GrossTax: 13750, Credits: 2250, NetTax: 11500, EffectiveRate: 0.153},
+		},
+		{
+			// Retirement capped at 10000; two dependents add 4000 * 0.15 = 600 of credits
+			name: "deductions and dependents", salary: 75000,
+			claims: models.Claims{Deductions: map[string]float64{"retirement": 12000, "union_dues": 600}, Dependents: 2},
+			want:   models.NetTax{Deductions: 10600, TaxableIncome: 64400, GrossTax: 11100, Credits: 2850, NetTax: 8250, EffectiveRate: 0.11},
+		},
+		{
+			// Retirement capped at 18% of the salary
+			name: "rate limit", salary: 20000,
+			claims: models.Claims{Deductions: map[string]float64{"retirement": 5000}},
+			want:   models.NetTax{Deductions: 3600, TaxableIncome: 16400, GrossTax: 2460, Credits: 2250, NetTax: 210, EffectiveRate: 0.011},
+		},
+		{
+			// Credits are non-refundable: they only cancel the tax
+			name: "credits above the tax", salary: 10000,
+			want: models.NetTax{TaxableIncome: 10000, GrossTax: 1500, Credits: 1500},
+		},
+		{
+			// Deductions never exceed the salary
+			name: "deductions above the salary", salary: 400,
+			claims: models.Claims{Deductions: map[string]float64{"union_dues": 600}},
+			want:   models.NetTax{Deductions: 400},
+		},
+		{
+			name: "disallowed deductions are ignored", salary: 75000,
+			claims: models.Claims{Deductions: map[string]float64{"childcare": 5000}},
+			want:   models.NetTax{TaxableIncome: 75000, GrossTax: 13750, Credits: 2250, NetTax: 11500, EffectiveRate: 0.153},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := calculator.CalculateNet(tc.salary, tc.claims, data); got != tc.want {
+				t.Errorf("expected %+v but got %+v", tc.want, got)
+			}
+		})
+	}
+
+	// Without allowances the brackets apply to the gross salary
+	withoutAllowances := &models.TaxCalculatorResponse{TaxBrackets: data.TaxBrackets}
+	if got := calculator.CalculateNet(75000, models.Claims{Dependents: 2}, withoutAllowances); got.NetTax != 13750 || got.Credits != 0 {
+		t.Errorf("expected 13750 of tax without credits but got %+v", got)
+	}
+
+	// While credits cover the tax, more salary costs nothing
+	if marginal := calculator.CalculateNetMarginal(10000, models.Claims{}, data); marginal.TaxOnNextThousand != 0 || marginal.Rate != 0.15 {
+		t.Errorf("expected no tax on the next thousand at the 0.15 bracket but got %+v", marginal)
+	}
+}
+
+func TestDisallowedDeductions(t *testing.T) {
+	claims := models.Claims{Deductions: map[string]float64{"union_dues": 600, "retirement": 5000, "childcare": 100}}
+	data := &models.TaxCalculatorResponse{Allowances: &models.Allowances{Deductions: map[string]models.DeductionLimit{"retirement": {}}}}
+
+	if got := DisallowedDeductions(claims, data); strings.Join(got, ",") != "childcare,union_dues" {
+		t.Errorf("expected childcare and union_dues to be disallowed but got %v", got)
+	}
+	if got := DisallowedDeductions(claims, &models.TaxCalculatorResponse{}); len(got) != 3 {
+		t.Errorf("expected every deduction to be disallowed without allowances but got %v", got)
+	}
+}
+
+func TestCalculateCombined(t *testing.T) {
+	calculator := NewTaxCalculator()
+	data := &models.TaxCalculatorResponse{Jurisdictions: []models.JurisdictionSchedule{
+		{Code: "US", TaxBrackets: []models.TaxBracket{{Min: 0, Max: 50000, Rate: 0.1}, {Min: 50000, Rate: 0.2}}},
+		{Code: "US-CA", TaxBrackets: []models.TaxBracket{{Min: 0, Max: 60000, Rate: 0.05}, {Min: 60000, Rate: 0.08}},
+			Allowances: &models.Allowances{Deductions: map[string]models.DeductionLimit{"retirement": {}}}},
+	}}
+
+	// Only US-CA allows the retirement deduction
+	claims := models.Claims{Deductions: map[string]float64{"retirement": 5000}}
+	if disallowed := DisallowedDeductions(claims, data); len(disallowed) > 0 {
+		t.Errorf("expected deductions allowed by one schedule to be accepted but got %v", disallowed)
+	}
+
+	combined := calculator.CalculateCombined(55000, claims, data)
+	if len(combined.Jurisdictions) != 2 {
+		t.Fatalf("expected 2 jurisdictions but got %+v", combined.Jurisdictions)
+	}
+	// US: 5000 + 1000; US-CA taxes 50000 at 0.05
+	us, state := combined.Jurisdictions[0], combined.Jurisdictions[1]
+	if us.NetTax != 6000 || us.Deductions != 0 || state.NetTax != 2500 || state.TaxableIncome != 50000 {
+		t.Errorf("unexpected jurisdictions: %+v and %+v", us, state)
+	}
+	if combined.Total.NetTax != 8500 || combined.Total.EffectiveRate != 0.155 {
+		t.Errorf("expected 8500 of combined tax but got %+v", combined.Total)
+	}
+	if combined.Marginal.Rate != 0.25 || combined.Marginal.TaxOnNextThousand != 250 || combined.Marginal.Bracket != nil {
+		t.Errorf("expected a combined marginal rate of 0.25 but got %+v", combined.Marginal)
+	}
+	// US-CA reaches its next bracket first: 10000 of taxable income away
+	if next := combined.Marginal.IncomeToNextBracket; next == nil || *next != 10000 {
+		t.Errorf("expected 10000 to the next bracket but got %v", next)
+	}
+}
+
+func TestValidateTaxData(t *testing.T) {
+	brackets := []models.TaxBracket{{Min: 0, Rate: 0.1}}
+	schedules := []models.JurisdictionSchedule{{Code: "US", TaxBrackets: brackets}, {Code: "US-CA", TaxBrackets: brackets}}
+	tests := []struct {
+		name          string
+		data          models.TaxCalculatorResponse
+		jurisdictions bool // Per-jurisdiction data is accepted
+		valid         bool
+	}{
+		{name: "single schedule", data: models.TaxCalculatorResponse{TaxBrackets: brackets}, valid: true},
+		{name: "single schedule for a jurisdiction", data: models.TaxCalculatorResponse{TaxBrackets: brackets}, jurisdictions: true, valid: true},
+		{name: "jurisdictions", data: models.TaxCalculatorResponse{Jurisdictions: schedules}, jurisdictions: true, valid: true},
+		{name: "jurisdictions only, without a jurisdiction", data: models.TaxCalculatorResponse{Jurisdictions: schedules}},
+		{name: "jurisdictions and brackets, without a jurisdiction", data: models.TaxCalculatorResponse{TaxBrackets: brackets, Jurisdictions: schedules}, valid: true},
+		{name: "no brackets", data: models.TaxCalculatorResponse{}},
+		{name: "jurisdiction without code", data: models.TaxCalculatorResponse{Jurisdictions: []models.JurisdictionSchedule{{TaxBrackets: brackets}}}, jurisdictions: true},
+		{name: "duplicate jurisdiction", data: models.TaxCalculatorResponse{Jurisdictions: []models.JurisdictionSchedule{{Code: "US", TaxBrackets: brackets}, {Code: "US", TaxBrackets: brackets}}}, jurisdictions: true},
+		{name: "jurisdiction without brackets", data: models.TaxCalculatorResponse{Jurisdictions: []models.JurisdictionSchedule{{Code: "US"}}}, jurisdictions: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTaxData(&tc.data, tc.jurisdictions)
+			if tc.valid && err != nil {
+				t.Errorf("expected valid tax data but got: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestJurisdictionTaxDataURL(t *testing.T) {
+	tests := []struct {
+		jurisdiction string
+		taxYear      int
+		want         string
+	}{
+		{want: "http://tax"},
+		{taxYear: 2022, want: "http://tax/tax-year/2022"},
+		{jurisdiction: "US-CA", want: "http://tax/jurisdiction/US-CA"},
+		{jurisdiction: "US-CA", taxYear: 2022, want: "http://tax/jurisdiction/US-CA/tax-year/2022"},
+	}
+
+	for _, tc := range tests {
+		if got := JurisdictionTaxDataURL("http://tax", tc.jurisdiction, tc.taxYear); got != tc.want {
+			t.Errorf("expected %s but got %s", tc.want, got)
+		}
+	}
+}
+
+func TestValidateAllowances(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowances *models.Allowances
+		valid      bool
+	}{
+		{name: "none", valid: true},
+		{name: "valid", allowances: &models.Allowances{CreditRate: 0.15, BasicPersonalAmount: 15000, Deductions: map[string]models.DeductionLimit{"retirement": {Max: 10000, MaxRate: 0.18}}}, valid: true},
+		{name: "credit rate above 1", allowances: &models.Allowances{CreditRate: 15}},
+		{name: "negative amount", allowances: &models.Allowances{CreditRate: 0.15, DependentAmount: -1}},
+		{name: "invalid limit", allowances: &models.Allowances{Deductions: map[string]models.DeductionLimit{"retirement": {MaxRate: 2}}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAllowances(tc.allowances)
+			if tc.valid && err != nil {
+				t.Errorf("expected valid allowances but got: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
 func TestFetchTaxData(t *testing.T) {
 	// Use the proper constructor to initialize the calculator with circuit breaker
 	calculator := NewTaxCalculator()
@@ -109,7 +448,7 @@ func TestFetchTaxData(t *testing.T) {
 
 	// Test successful request
 	t.Run("Successful request", func(t *testing.T) {
-		resp, err := calculator.FetchTaxData(mockServer.URL)
+		resp, err := calculator.FetchTaxData(context.Background(), mockServer.URL, 0)
 
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
@@ -131,7 +470,7 @@ func TestFetchTaxData(t *testing.T) {
 		}))
 		defer errorServer.Close()
 
-		_, err := calculator.FetchTaxData(errorServer.URL)
+		_, err := calculator.FetchTaxData(context.Background(), errorServer.URL, 0)
 
 		if err == nil {
 			t.Errorf("expected error but got none")
@@ -145,7 +484,7 @@ func TestFetchTaxData(t *testing.T) {
 		}))
 		defer badDataServer.Close()
 
-		_, err := calculator.FetchTaxData(badDataServer.URL)
+		_, err := calculator.FetchTaxData(context.Background(), badDataServer.URL, 0)
 
 		if err == nil {
 			t.Errorf("expected error but got none")
@@ -159,7 +498,7 @@ func TestFetchTaxData(t *testing.T) {
 		}))
 		defer emptyServer.Close()
 
-		_, err := calculator.FetchTaxData(emptyServer.URL)
+		_, err := calculator.FetchTaxData(context.Background(), emptyServer.URL, 0)
 
 		if err == nil {
 			t.Errorf("expected error but got none")
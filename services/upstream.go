@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Error kinds used to label upstream call metrics
+const (
+	upstreamErrorNone        = "none"
+	upstreamErrorRequest     = "request_error"
+	upstreamErrorTimeout     = "timeout"
+	upstreamErrorCanceled    = "canceled" // The caller went away before the tax calculator answered
+	upstreamErrorConnRefused = "connection_refused"
+	upstreamErrorConnection  = "connection_error"
+	upstreamErrorStatus      = "http_status"
+	upstreamErrorRead        = "read_error"
+	upstreamErrorDecode      = "decode_error"
+	upstreamErrorValidation  = "validation_error"
+)
+
+// statusClassLabel returns the HTTP status class (e.g. "2xx") or "none" if no response was received
+func statusClassLabel(statusCode int) string {
+	if statusCode <= 0 {
+		return "none"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
+
+// classifyTransportError maps an error returned by http.Client.Do to an error kind
+func classifyTransportError(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.Canceled) {
+		return upstreamErrorCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return upstreamErrorTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return upstreamErrorConnRefused
+	}
+	return upstreamErrorConnection
+}
+
+// isBreakerSuccess reports whether the outcome of an upstream call counts as a
+// success for the circuit breaker. Calls cancelled by the client and client
+// errors such as a 404 for an unknown year say nothing about the tax
+// calculator's health, so they never count as failures; 429 (the tax
+// calculator shedding load) still does.
+func isBreakerSuccess(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && statusErr.StatusCode != http.StatusTooManyRequests
+	}
+	return err == nil || errors.Is(err, context.Canceled)
+}
+
+// classifyReadError maps an error returned while reading the response body to an error kind
+func classifyReadError(err error) string {
+	if kind := classifyTransportError(err); kind == upstreamErrorTimeout || kind == upstreamErrorCanceled {
+		return kind
+	}
+	return upstreamErrorRead
+}
+
+// withConnectionTrace attaches an httptrace.ClientTrace that records DNS, connect,
+// TLS and time-to-first-byte durations for the request. Happy eyeballs may dial
+// several addresses in parallel, so connect starts are kept per address and the
+// hooks are synchronized.
+func (tc *TaxCalculator) withConnectionTrace(req *http.Request) *http.Request {
+	var mu sync.Mutex
+	var dnsStart, tlsStart time.Time
+	connectStarts := map[string]time.Time{}
+	requestStart := time.Now()
+
+	observe := func(phase string, start time.Time) {
+		if start.IsZero() {
+			return
+		}
+		tc.metrics.UpstreamConnectionPhaseDuration.WithLabelValues(phase, tc.environment).Observe(time.Since(start).Seconds())
+	}
+	// started returns the start time recorded in *start, under the lock
+	started := func(start *time.Time) time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return *start
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) { observe("dns", started(&dnsStart)) },
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connectStarts[network+"/"+addr] = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			start := connectStarts[network+"/"+addr]
+			delete(connectStarts, network+"/"+addr)
+			mu.Unlock()
+			observe("connect", start)
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) { observe("tls", started(&tlsStart)) },
+		GotFirstResponseByte: func() {
+			observe("ttfb", requestStart)
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pulsegrade/test1/metrics"
+	"pulsegrade/test1/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestUpstreamMetrics(t *testing.T) {
+	registry := metrics.NewRegistry(metrics.Options{})
+	calculator := NewTaxCalculatorWithFullConfig("test", false, models.CircuitBreakerConfig{}, registry)
+	calculator.SetConnectionTracing(true)
+
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"max":50000,"rate":0.15},{"min":50000,"rate":0.25}]}`)
+	}))
+	defer okServer.Close()
+
+	badDataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"invalid_json":true`)
+	}))
+	defer badDataServer.Close()
+
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer errorServer.Close()
+
+	closedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedURL := closedServer.URL
+	closedServer.Close()
+
+	calculator.FetchTaxData(context.Background(), okServer.URL, 2022)
+	calculator.FetchTaxData(context.Background(), badDataServer.URL, 2022)
+	calculator.FetchTaxData(context.Background(), errorServer.URL, 0)
+	calculator.FetchTaxData(context.Background(), closedURL, 2021)
+
+	tests := []struct {
+		name        string
+		taxYear     string
+		statusClass string
+		errorKind   string
+	}{
+		{name: "Success", taxYear: "2022", statusClass: "2xx", errorKind: upstreamErrorNone},
+		{name: "Decode error", taxYear: "2022", statusClass: "2xx", errorKind: upstreamErrorDecode},
+		{name: "HTTP error status", taxYear: "default", statusClass: "5xx", errorKind: upstreamErrorStatus},
+		{name: "Connection refused", taxYear: "2021", statusClass: "none", errorKind: upstreamErrorConnRefused},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			count := registry.CounterValue("taxapp_upstream_requests_total", prometheus.Labels{
+				"tax_year": tc.taxYear, "status_class": tc.statusClass, "error_kind": tc.errorKind,
+			})
+			if count != 1 {
+				t.Errorf("expected 1 upstream call labeled %s/%s/%s but got %v", tc.taxYear, tc.statusClass, tc.errorKind, count)
+			}
+		})
+	}
+
+	t.Run("Connection phases recorded", func(t *testing.T) {
+		if count := registry.HistogramCount("taxapp_upstream_connection_phase_seconds", prometheus.Labels{"phase": "ttfb"}); count != 3 {
+			t.Errorf("expected 3 time-to-first-byte observations but got %d", count)
+		}
+	})
+
+	t.Run("Bracket fetch time recorded", func(t *testing.T) {
+		fetched := registry.GaugeValue("taxapp_tax_bracket_fetch_time_seconds", prometheus.Labels{"tax_year": "2022"})
+		if age := time.Since(time.Unix(int64(fetched), 0)); age < 0 || age > time.Minute {
+			t.Errorf("expected the 2022 brackets to have been fetched just now but got %v", fetched)
+		}
+		if fetched := registry.GaugeValue("taxapp_tax_bracket_fetch_time_seconds", prometheus.Labels{"tax_year": "2021"}); fetched != 0 {
+			t.Errorf("expected no fetch time for the failed year but got %v", fetched)
+		}
+	})
+
+	t.Run("Errors counted once", func(t *testing.T) {
+		if count := registry.CounterValue("taxapp_tax_service_errors_total", nil); count != 3 {
+			t.Errorf("expected 3 tax service errors but got %v", count)
+		}
+	})
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"rate":0.15}]}`)
+	}))
+	defer server.Close()
+
+	calculator := NewTaxCalculatorWithFullConfig("test", true, models.CircuitBreakerConfig{
+		RequestThreshold: 5, FailureRatio: 0.5, Timeout: 60, MaxHalfOpenReqs: 1,
+	}, metrics.NewNoop())
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	if _, err := calculator.FetchTaxData(ctx, server.URL, 2022); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	traceID := parent.SpanContext().TraceID().String()
+	if !strings.Contains(traceparent, traceID) {
+		t.Errorf("expected traceparent header to carry trace ID %s but got %q", traceID, traceparent)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	fetchSpan, ok := spans["FetchTaxData"]
+	if !ok {
+		t.Fatalf("expected a FetchTaxData span")
+	}
+	if fetchSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected FetchTaxData span to be a child of the request span")
+	}
+
+	decision := ""
+	for _, attr := range fetchSpan.Attributes() {
+		if attr.Key == "circuit_breaker.decision" {
+			decision = attr.Value.AsString()
+		}
+	}
+	if decision != "allowed" {
+		t.Errorf("expected circuit breaker decision 'allowed' but got %q", decision)
+	}
+
+	if _, ok := spans["GET tax-calculator"]; !ok {
+		t.Errorf("expected a client span for the upstream call")
+	}
+}
+
+func TestCanceledRequestsKeepBreakerClosed(t *testing.T) {
+	// The tax calculator is slow but healthy: it only stops when the client goes away
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry(metrics.Options{})
+	calculator := NewTaxCalculatorWithFullConfig("test", true, models.CircuitBreakerConfig{
+		RequestThreshold: 2, FailureRatio: 0.5, Timeout: 60, MaxHalfOpenReqs: 1,
+	}, registry)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		if _, err := calculator.FetchTaxData(ctx, server.URL, 2022); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the call to be canceled but got %v", err)
+		}
+		cancel()
+	}
+
+	if state := calculator.cb.State(); state != gobreaker.StateClosed {
+		t.Errorf("expected canceled calls to leave the breaker closed but it is %v", state)
+	}
+	if count := registry.CounterValue("taxapp_upstream_requests_total", prometheus.Labels{
+		"tax_year": "2022", "status_class": "none", "error_kind": upstreamErrorCanceled,
+	}); count != 3 {
+		t.Errorf("expected 3 upstream calls labeled canceled but got %v", count)
+	}
+}
+
+func TestClientErrorsKeepBreakerClosed(t *testing.T) {
+	tests := []struct {
+		status int
+		state  gobreaker.State
+	}{
+		{status: http.StatusNotFound, state: gobreaker.StateClosed},
+		{status: http.StatusBadRequest, state: gobreaker.StateClosed},
+		{status: http.StatusTooManyRequests, state: gobreaker.StateOpen},
+		{status: http.StatusServiceUnavailable, state: gobreaker.StateOpen},
+	}
+
+	for _, tc := range tests {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			calculator := NewTaxCalculatorWithFullConfig("test", true, models.CircuitBreakerConfig{
+				RequestThreshold: 2, FailureRatio: 0.5, Timeout: 60, MaxHalfOpenReqs: 1,
+			}, metrics.NewNoop())
+			for i := 0; i < 3; i++ {
+				calculator.FetchTaxData(context.Background(), server.URL+"/tax-year/1999", 1999)
+			}
+
+			if state := calculator.cb.State(); state != tc.state {
+				t.Errorf("expected the breaker to be %v after %d responses but it is %v", tc.state, tc.status, state)
+			}
+		})
+	}
+}
+
+func TestProbeTaxDataCache(t *testing.T) {
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		fmt.Fprint(w, `{"tax_brackets":[{"min":0,"rate":0.1}]}`)
+	}))
+	defer server.Close()
+
+	calculator := NewTaxCalculatorWithFullConfig("test", true, models.CircuitBreakerConfig{}, metrics.NewNoop())
+	for i := 0; i < 3; i++ {
+		if err := calculator.ProbeTaxData(context.Background(), server.URL); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if probes.Load() != 1 {
+		t.Errorf("expected readiness checks within the TTL to share one probe but got %d", probes.Load())
+	}
+
+	calculator.SetProbeCacheTTL(0)
+	calculator.ProbeTaxData(context.Background(), server.URL)
+	calculator.ProbeTaxData(context.Background(), server.URL)
+	if probes.Load() != 3 {
+		t.Errorf("expected every probe to call the tax calculator without a TTL but got %d", probes.Load())
+	}
+}
+
+func TestConnectionTraceParallelDials(t *testing.T) {
+	registry := metrics.NewRegistry(metrics.Options{})
+	calculator := NewTaxCalculatorWithFullConfig("test", false, models.CircuitBreakerConfig{}, registry)
+
+	req := calculator.withConnectionTrace(httptest.NewRequest(http.MethodGet, "http://tax-calculator", nil))
+	trace := httptrace.ContextClientTrace(req.Context())
+
+	// Happy eyeballs dials the IPv4 and IPv6 addresses in parallel
+	var wg sync.WaitGroup
+	for _, addr := range []string{"10.0.0.1:80", "[fd00::1]:80"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trace.ConnectStart("tcp", addr)
+			trace.ConnectDone("tcp", addr, nil)
+		}()
+	}
+	wg.Wait()
+
+	if count := registry.HistogramCount("taxapp_upstream_connection_phase_seconds", prometheus.Labels{"phase": "connect"}); count != 2 {
+		t.Errorf("expected 2 connect observations but got %d", count)
+	}
+}
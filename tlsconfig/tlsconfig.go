@@ -0,0 +1,155 @@
+// Package tlsconfig builds the server TLS configuration, including mutual TLS
+// and automatic certificate reloading
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"pulsegrade/test1/models"
+)
+
+// Client certificate modes
+const (
+	ClientAuthNone          = "none"            // Client certificates are not requested
+	ClientAuthVerifyIfGiven = "verify-if-given" // Client certificates are optional but verified when presented
+	ClientAuthRequire       = "require"         // Every client must present a certificate signed by the CA bundle
+)
+
+// New creates the server TLS configuration described by config, along with the
+// reloader serving its certificate. HTTP/2 is negotiated via ALPN.
+func New(config models.TLSConfig) (*tls.Config, *CertReloader, error) {
+	reloader, err := NewCertReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	minVersion, err := parseVersion(config.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cipherSuites, err := parseCipherSuites(config.CipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkHTTP2CipherSuites(cipherSuites, minVersion); err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if config.ClientCAFile != "" {
+		pool, err := loadCertPool(config.ClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	tlsConfig.ClientAuth, err = parseClientAuth(config.ClientAuth, config.ClientCAFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tlsConfig, reloader, nil
+}
+
+// parseVersion converts "1.2" or "1.3" to a TLS version (empty means TLS 1.2)
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS minimum version %q (expected 1.2 or 1.3)", version)
+	}
+}
+
+// parseCipherSuites converts cipher suite names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+// to their IDs. Insecure suites are rejected. An empty list uses Go's defaults; TLS 1.3
+// suites are not configurable.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	available := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := available[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// checkHTTP2CipherSuites rejects a TLS 1.2 cipher suite list without one of the
+// suites HTTP/2 requires (RFC 7540 section 9.2.2); Go's HTTP/2 server would otherwise
+// refuse to start. The list does not apply when TLS 1.3 is the minimum version.
+func checkHTTP2CipherSuites(ids []uint16, minVersion uint16) error {
+	if len(ids) == 0 || minVersion >= tls.VersionTLS13 {
+		return nil
+	}
+	for _, id := range ids {
+		if id == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || id == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return nil
+		}
+	}
+	return fmt.Errorf("TLS cipher suites must include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, which HTTP/2 requires")
+}
+
+// parseClientAuth converts a client certificate mode to its tls.ClientAuthType.
+// When a CA bundle is configured the mode defaults to require.
+func parseClientAuth(mode, clientCAFile string) (tls.ClientAuthType, error) {
+	if mode == "" {
+		mode = ClientAuthNone
+		if clientCAFile != "" {
+			mode = ClientAuthRequire
+		}
+	}
+
+	switch mode {
+	case ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthVerifyIfGiven, ClientAuthRequire:
+		if clientCAFile == "" {
+			return 0, fmt.Errorf("TLS client auth %q requires clientCAFile", mode)
+		}
+		if mode == ClientAuthRequire {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS client auth %q (expected none, verify-if-given or require)", mode)
+	}
+}
+
+// loadCertPool reads a PEM bundle of CA certificates
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", path)
+	}
+	return pool, nil
+}
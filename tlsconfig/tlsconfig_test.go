@@ -0,0 +1,215 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pulsegrade/test1/models"
+)
+
+// testCA is a throwaway certificate authority for issuing test certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue creates a certificate for localhost signed by the CA, returning PEM cert and key
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+
+	tlsConfig, _, err := New(models.TLSConfig{
+		CertFile:     writeFile(t, dir, "server.crt", serverCert),
+		KeyFile:      writeFile(t, dir, "server.key", serverKey),
+		MinVersion:   "1.2",
+		ClientCAFile: writeFile(t, dir, "ca.crt", ca.pem),
+	})
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig: tlsConfig,
+		ErrorLog:  log.New(io.Discard, "", 0),
+	}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+	url := "https://" + listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+
+	t.Run("Client without certificate is rejected", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+		if _, err := client.Get(url); err == nil {
+			t.Errorf("expected handshake to fail without a client certificate")
+		}
+	})
+
+	t.Run("Client with certificate uses HTTP/2", func(t *testing.T) {
+		pair, _ := tls.X509KeyPair(clientCert, clientKey)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{pair}},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("expected request to succeed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2 but got %s", resp.Proto)
+		}
+	})
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	certFile := writeFile(t, dir, "server.crt", cert)
+	keyFile := writeFile(t, dir, "server.key", key)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("failed to create reloader: %v", err)
+	}
+
+	serial := func() int64 {
+		current, _ := reloader.GetCertificate(nil)
+		leaf, _ := x509.ParseCertificate(current.Certificate[0])
+		return leaf.SerialNumber.Int64()
+	}
+
+	if reloaded, _ := reloader.reload(); reloaded {
+		t.Errorf("expected no reload when files are unchanged")
+	}
+
+	// Replace the certificate, as a renewal would
+	cert, key = ca.issue(t, 11, x509.ExtKeyUsageServerAuth)
+	writeFile(t, dir, "server.crt", cert)
+	writeFile(t, dir, "server.key", key)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+
+	if reloaded, err := reloader.reload(); !reloaded || err != nil {
+		t.Fatalf("expected certificate to be reloaded but got reloaded=%v err=%v", reloaded, err)
+	}
+	if serial() != 11 {
+		t.Errorf("expected renewed certificate with serial 11 but got %d", serial())
+	}
+
+	// A broken renewal keeps the previous certificate
+	writeFile(t, dir, "server.key", []byte("not a key"))
+	os.Chtimes(keyFile, later.Add(time.Minute), later.Add(time.Minute))
+	if _, err := reloader.reload(); err == nil {
+		t.Errorf("expected an error for an invalid key")
+	}
+	if serial() != 11 {
+		t.Errorf("expected previous certificate to be kept but got serial %d", serial())
+	}
+}
+
+func TestInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{name: "Unknown TLS version", run: func() error { _, err := parseVersion("1.0"); return err }},
+		{name: "Insecure cipher suite", run: func() error { _, err := parseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); return err }},
+		{name: "Cipher suites without HTTP/2 suite", run: func() error {
+			return checkHTTP2CipherSuites([]uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, tls.VersionTLS12)
+		}},
+		{name: "Require without CA bundle", run: func() error { _, err := parseClientAuth(ClientAuthRequire, ""); return err }},
+		{name: "Missing key file", run: func() error { _, err := NewCertReloader("server.crt", ""); return err }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.run(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+
+	t.Run("Cipher suites with HTTP/2 suite", func(t *testing.T) {
+		suites := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+		if err := checkHTTP2CipherSuites(suites, tls.VersionTLS12); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := checkHTTP2CipherSuites(suites[:1], tls.VersionTLS13); err != nil {
+			t.Errorf("expected TLS 1.3 to ignore the list but got %v", err)
+		}
+	})
+}
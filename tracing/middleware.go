@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"net/http"
+
+	"pulsegrade/test1/httpwriter"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing any trace
+// context received in the W3C traceparent header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		sw := httpwriter.New(w)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(sw, r)
+
+		// Name the span after the matched route once the ServeMux has dispatched the request
+		if r.Pattern != "" {
+			span.SetName(r.Method + " " + r.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+		}
+
+		span.SetAttributes(attribute.Int(string(semconv.HTTPResponseStatusCodeKey), sw.StatusCode()))
+		if sw.StatusCode() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.StatusCode()))
+		}
+	})
+}
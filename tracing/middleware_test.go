@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/income-salary", func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanContextFromContext(r.Context()).IsValid() {
+			t.Errorf("expected handler context to carry a span")
+		}
+	})
+	mux.HandleFunc("/failing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := Middleware(mux)
+
+	req := httptest.NewRequest("GET", "/income-salary?salary=50000", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/failing", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans but got %d", len(spans))
+	}
+
+	t.Run("Span named after route and continues incoming trace", func(t *testing.T) {
+		span := spans[0]
+		if span.Name() != "GET /income-salary" {
+			t.Errorf("expected span name 'GET /income-salary' but got %q", span.Name())
+		}
+		if span.SpanKind() != trace.SpanKindServer {
+			t.Errorf("expected server span but got %v", span.SpanKind())
+		}
+		if traceID := span.SpanContext().TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected span to continue incoming trace but got trace ID %s", traceID)
+		}
+	})
+
+	t.Run("Server errors mark the span as failed", func(t *testing.T) {
+		if code := spans[1].Status().Code; code.String() != "Error" {
+			t.Errorf("expected error status but got %v", code)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// Package tracing configures OpenTelemetry distributed tracing for the application
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"pulsegrade/test1/logger"
+	"pulsegrade/test1/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer used throughout the application
+const instrumentationName = "pulsegrade/test1"
+
+// Available span exporters
+const (
+	ExporterOTLP   = "otlp"   // OTLP over HTTP to a collector
+	ExporterStdout = "stdout" // Pretty-printed spans on stdout
+	ExporterFile   = "file"   // JSON spans appended to a file
+)
+
+// ShutdownFunc flushes pending spans and releases exporter resources
+type ShutdownFunc func(ctx context.Context) error
+
+// Tracer returns the application tracer from the global tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the global tracer provider and W3C trace context propagator
+// described by the configuration. When tracing is disabled the global no-op
+// provider is left in place, but propagation is still configured so incoming
+// trace context is forwarded to the upstream tax calculator.
+func Setup(config models.TracingConfig, environment string) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Route exporter errors through the application logger (and its sampling)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("OpenTelemetry error: %v", err)
+	}))
+
+	exporter, closeOutput, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+		semconv.DeploymentEnvironment(environment),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	logger.Info("Tracing enabled: exporter=%s, sampleRatio=%.2f", config.Exporter, config.SampleRatio)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeOutput != nil {
+			closeOutput.Close()
+		}
+		return err
+	}, nil
+}
+
+// newExporter creates the span exporter selected by the configuration, along
+// with any file that must be closed on shutdown
+func newExporter(config models.TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch strings.ToLower(config.Exporter) {
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP trace exporter: %v", err)
+		}
+		return exporter, nil, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout trace exporter: %v", err)
+		}
+		return exporter, nil, nil
+	case ExporterFile:
+		file, err := os.OpenFile(config.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to create file trace exporter: %v", err)
+		}
+		return exporter, file, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q (expected otlp, stdout or file)", config.Exporter)
+	}
+}
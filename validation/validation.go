@@ -0,0 +1,162 @@
+// Package validation checks calculation inputs against the configured bounds so
+// every entry point (query, body, batch item) rejects the same values the same way
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pulsegrade/test1/models"
+)
+
+// Field error codes
+const (
+	CodeRequired   = "required"     // The field is missing
+	CodeInvalid    = "invalid"      // The field is not a (finite) number
+	CodeOutOfRange = "out_of_range" // The field is outside the configured bounds
+)
+
+// Rules validates salaries and tax years
+type Rules struct {
+	config models.ValidationConfig
+	now    func() time.Time
+}
+
+// New creates rules enforcing the given bounds. A zero MaxSalary or MinTaxYear
+// leaves that bound open; a zero MaxTaxYear means the current year.
+func New(config models.ValidationConfig) *Rules {
+	return &Rules{config: config, now: time.Now}
+}
+
+// Salary checks a salary: it must be finite and within [MinSalary, MaxSalary].
+// Salaries below zero are always rejected; zero is a valid salary owing no tax.
+func (v *Rules) Salary(salary float64) *models.FieldError {
+	switch {
+	case math.IsNaN(salary) || math.IsInf(salary, 0):
+		return fieldError("salary", CodeInvalid, "salary must be a finite number")
+	case salary < math.Max(v.config.MinSalary, 0):
+		return fieldError("salary", CodeOutOfRange, fmt.Sprintf("salary must be at least %s", formatAmount(math.Max(v.config.MinSalary, 0))))
+	case v.config.MaxSalary > 0 && salary > v.config.MaxSalary:
+		return fieldError("salary", CodeOutOfRange, fmt.Sprintf("salary must be at most %s", formatAmount(v.config.MaxSalary)))
+	}
+	return nil
+}
+
+// TaxYear checks a requested tax year; 0 (no year, the default year) is always valid
+func (v *Rules) TaxYear(year int) *models.FieldError {
+	if year == 0 {
+		return nil
+	}
+	min, max := v.TaxYearRange()
+	if year < min || year > max {
+		return fieldError("year", CodeOutOfRange, fmt.Sprintf("year must be between %d and %d, got %d", min, max, year))
+	}
+	return nil
+}
+
+// TaxYearRange returns the supported tax years, inclusive
+func (v *Rules) TaxYearRange() (int, int) {
+	min := v.config.MinTaxYear
+	if min < 1 {
+		min = 1
+	}
+	max := v.config.MaxTaxYear
+	if max == 0 {
+		max = v.now().Year()
+	}
+	return min, max
+}
+
+// deductionType matches the names of deduction types, such as union_dues
+var deductionType = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// MaxDependents bounds the dependents a calculation can claim
+const MaxDependents = 50
+
+// Deduction checks a claimed deduction: it must be a finite, non-negative amount
+// of a type named with lowercase letters, digits and underscores. Whether the
+// tax year allows the type is checked once its allowances are known.
+func (v *Rules) Deduction(name string, amount float64) *models.FieldError {
+	field := "deductions." + name
+	switch {
+	case !deductionType.MatchString(name):
+		return fieldError("deductions", CodeInvalid, fmt.Sprintf("deduction types must be lowercase letters, digits and underscores, got %q", name))
+	case math.IsNaN(amount) || math.IsInf(amount, 0):
+		return fieldError(field, CodeInvalid, fmt.Sprintf("%s must be a finite number", field))
+	case amount < 0:
+		return fieldError(field, CodeOutOfRange, fmt.Sprintf("%s must not be negative", field))
+	}
+	return nil
+}
+
+// ParseDeductions parses and checks deductions given as comma-separated
+// type:amount pairs (retirement:5000,union_dues:600), reporting every invalid pair
+func (v *Rules) ParseDeductions(value string) (map[string]float64, []models.FieldError) {
+	deductions := map[string]float64{}
+	var fields []models.FieldError
+	for _, pair := range strings.Split(value, ",") {
+		name, amountStr, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			fields = append(fields, *fieldError("deductions", CodeInvalid, fmt.Sprintf("deductions must be type:amount pairs, got %q", pair)))
+			continue
+		}
+		name = strings.TrimSpace(name)
+		amount, err := strconv.ParseFloat(strings.TrimSpace(amountStr), 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			fields = append(fields, *fieldError("deductions."+name, CodeInvalid, fmt.Sprintf("deductions.%s must be a number, got %q", name, amountStr)))
+			continue
+		}
+		if field := v.Deduction(name, amount); field != nil {
+			fields = append(fields, *field)
+			continue
+		}
+		deductions[name] += amount
+	}
+	return deductions, fields
+}
+
+// Dependents checks the number of dependents claimed
+func (v *Rules) Dependents(dependents int) *models.FieldError {
+	if dependents < 0 || dependents > MaxDependents {
+		return fieldError("dependents", CodeOutOfRange, fmt.Sprintf("dependents must be between 0 and %d, got %d", MaxDependents, dependents))
+	}
+	return nil
+}
+
+// jurisdictionCode matches jurisdiction codes: a country, optionally followed by
+// a subdivision (US, US-CA, CA-ON)
+var jurisdictionCode = regexp.MustCompile(`^[A-Z]{2}(-[A-Z0-9]{1,3})?$`)
+
+// Jurisdiction checks a jurisdiction code, given in upper case
+func (v *Rules) Jurisdiction(code string) *models.FieldError {
+	if !jurisdictionCode.MatchString(code) {
+		return fieldError("jurisdiction", CodeInvalid, fmt.Sprintf("jurisdiction must be a country code, optionally with a subdivision (US, US-CA), got %q", code))
+	}
+	return nil
+}
+
+// Check validates a salary and tax year, returning every invalid field
+func (v *Rules) Check(salary float64, year int) []models.FieldError {
+	var fields []models.FieldError
+	for _, field := range []*models.FieldError{v.Salary(salary), v.TaxYear(year)} {
+		if field != nil {
+			fields = append(fields, *field)
+		}
+	}
+	return fields
+}
+
+// fieldError creates a field error
+func fieldError(field, code, message string) *models.FieldError {
+	return &models.FieldError{Field: field, Code: code, Message: message}
+}
+
+// formatAmount formats a bound without exponent or trailing zeros
+func formatAmount(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
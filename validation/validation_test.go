@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"pulsegrade/test1/models"
+)
+
+func TestRules(t *testing.T) {
+	rules := New(models.ValidationConfig{MinSalary: 0, MaxSalary: 1e9, MinTaxYear: 2000})
+	rules.now = func() time.Time { return time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		salary float64
+		year   int
+		codes  map[string]string // Expected field error codes by field
+	}{
+		{name: "Valid", salary: 85000, year: 2022},
+		{name: "Zero salary and default year", salary: 0, year: 0},
+		{name: "Bounds are inclusive", salary: 1e9, year: 2026},
+		{name: "Negative salary", salary: -1, year: 2022, codes: map[string]string{"salary": CodeOutOfRange}},
+		{name: "Salary above the maximum", salary: 1e308, year: 2022, codes: map[string]string{"salary": CodeOutOfRange}},
+		{name: "NaN salary", salary: math.NaN(), year: 2022, codes: map[string]string{"salary": CodeInvalid}},
+		{name: "Infinite salary", salary: math.Inf(1), year: 2022, codes: map[string]string{"salary": CodeInvalid}},
+		{name: "Year before the minimum", salary: 50000, year: 1, codes: map[string]string{"year": CodeOutOfRange}},
+		{name: "Future year", salary: 50000, year: 2027, codes: map[string]string{"year": CodeOutOfRange}},
+		{name: "Both invalid", salary: -5, year: 99999, codes: map[string]string{"salary": CodeOutOfRange, "year": CodeOutOfRange}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := rules.Check(tc.salary, tc.year)
+			if len(fields) != len(tc.codes) {
+				t.Fatalf("expected %d field errors but got %+v", len(tc.codes), fields)
+			}
+			for _, field := range fields {
+				if tc.codes[field.Field] != field.Code {
+					t.Errorf("expected code %q for %s but got %q (%s)", tc.codes[field.Field], field.Field, field.Code, field.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenBounds(t *testing.T) {
+	rules := New(models.ValidationConfig{MaxTaxYear: 2030})
+
+	if field := rules.Salary(1e300); field != nil {
+		t.Errorf("expected no salary limit but got %s", field.Message)
+	}
+	if field := rules.Salary(-0.01); field == nil {
+		t.Errorf("expected negative salaries to be rejected without a configured minimum")
+	}
+	if min, max := rules.TaxYearRange(); min != 1 || max != 2030 {
+		t.Errorf("expected years 1-2030 but got %d-%d", min, max)
+	}
+}
+
+func TestClaims(t *testing.T) {
+	rules := New(models.ValidationConfig{})
+
+	if field := rules.Deduction("retirement", 5000); field != nil {
+		t.Errorf("expected a valid deduction but got %+v", field)
+	}
+	if field := rules.Deduction("retirement", -1); field == nil || field.Field != "deductions.retirement" || field.Code != CodeOutOfRange {
+		t.Errorf("expected a negative deduction to be out of range but got %+v", field)
+	}
+	if field := rules.Deduction("retirement", math.NaN()); field == nil || field.Code != CodeInvalid {
+		t.Errorf("expected a NaN deduction to be invalid but got %+v", field)
+	}
+	for _, name := range []string{"", "Union Dues", "a:b"} {
+		if field := rules.Deduction(name, 100); field == nil || field.Field != "deductions" {
+			t.Errorf("expected deduction type %q to be invalid but got %+v", name, field)
+		}
+	}
+
+	if field := rules.Dependents(2); field != nil {
+		t.Errorf("expected 2 dependents to be valid but got %+v", field)
+	}
+	for _, dependents := range []int{-1, MaxDependents + 1} {
+		if field := rules.Dependents(dependents); field == nil || field.Code != CodeOutOfRange {
+			t.Errorf("expected %d dependents to be out of range but got %+v", dependents, field)
+		}
+	}
+}
+
+func TestParseDeductions(t *testing.T) {
+	rules := New(models.ValidationConfig{})
+
+	deductions, fields := rules.ParseDeductions("retirement:5000, union_dues:600,retirement:1000")
+	if len(fields) > 0 {
+		t.Fatalf("expected no field errors but got %+v", fields)
+	}
+	if deductions["retirement"] != 6000 || deductions["union_dues"] != 600 {
+		t.Errorf("expected repeated types to add up but got %v", deductions)
+	}
+
+	if _, fields := rules.ParseDeductions("retirement,union_dues:abc,childcare:-1"); len(fields) != 3 {
+		t.Errorf("expected every invalid pair to be reported but got %+v", fields)
+	}
+}
+
+func TestJurisdiction(t *testing.T) {
+	rules := New(models.ValidationConfig{})
+
+	for _, code := range []string{"US", "US-CA", "CA-ON", "GB-ENG"} {
+		if field := rules.Jurisdiction(code); field != nil {
+			t.Errorf("expected %q to be valid but got %+v", code, field)
+		}
+	}
+	for _, code := range []string{"", "us", "USA", "US-", "US-CALI", "US/CA"} {
+		if field := rules.Jurisdiction(code); field == nil || field.Code != CodeInvalid {
+			t.Errorf("expected %q to be invalid but got %+v", code, field)
+		}
+	}
+}
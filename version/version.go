@@ -0,0 +1,75 @@
+// Package version reports which build of the application is running
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X pulsegrade/test1/version.Version=v1.2.3 -X pulsegrade/test1/version.Commit=abc1234"
+//
+// Values left empty are filled from the build information embedded by the Go toolchain.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a working tree with uncommitted changes
+}
+
+// Get returns the build information, preferring values set through -ldflags and
+// falling back to the module version and VCS stamping from debug.ReadBuildInfo
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && buildInfo.Main.Version != "" && buildInfo.Main.Version != "(devel)" {
+			info.Version = buildInfo.Main.Version
+		}
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = shortCommit(setting.Value)
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+
+	return info
+}
+
+// shortCommit abbreviates a full commit hash to the conventional 7 characters
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
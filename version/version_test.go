@@ -0,0 +1,29 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGetPrefersLinkerValues(t *testing.T) {
+	defer func(version, commit, buildDate string) {
+		Version, Commit, BuildDate = version, commit, buildDate
+	}(Version, Commit, BuildDate)
+
+	Version, Commit, BuildDate = "v1.2.3", "abc1234", "2024-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc1234" || info.BuildDate != "2024-01-02T03:04:05Z" {
+		t.Errorf("expected linker values to be reported but got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("expected Go version %s but got %s", runtime.Version(), info.GoVersion)
+	}
+}
+
+func TestGetDefaults(t *testing.T) {
+	info := Get()
+	if info.Version == "" || info.Commit == "" {
+		t.Errorf("expected version and commit to fall back to placeholders but got %+v", info)
+	}
+}